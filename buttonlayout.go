@@ -0,0 +1,227 @@
+// window button layout editor
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// buttons offered in the layout editor, even if absent from the current layout
+var layoutButtons = []string{"menu", "appmenu", "icon", "minimize", "maximize", "close"}
+
+// titlebar click actions, as in the org.gnome.desktop.wm.preferences enum
+var titlebarActions = []string{
+	"toggle-maximize",
+	"toggle-maximize-horizontally",
+	"toggle-maximize-vertically",
+	"toggle-shade",
+	"minimize",
+	"lower",
+	"menu",
+	"none",
+}
+
+// buttonLayout is the button-layout value split into placement zones
+type buttonLayout struct {
+	left   []string
+	right  []string
+	unused []string
+}
+
+// parseButtonLayout parses a "left:right" layout, e.g. "appmenu:minimize,maximize,close"
+func parseButtonLayout(layout string) buttonLayout {
+	var l buttonLayout
+	leftPart, rightPart, _ := strings.Cut(layout, ":")
+	split := func(s string) []string {
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" && !isIn(l.left, item) && !isIn(items, item) {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	l.left = split(leftPart)
+	l.right = split(rightPart)
+	for _, b := range layoutButtons {
+		if !isIn(l.left, b) && !isIn(l.right, b) {
+			l.unused = append(l.unused, b)
+		}
+	}
+	return l
+}
+
+func (l buttonLayout) String() string {
+	return fmt.Sprintf("%s:%s", strings.Join(l.left, ","), strings.Join(l.right, ","))
+}
+
+// gtkDecorationLayout converts the GNOME button-layout value to a GTK decoration layout. GTK has no
+// "appmenu" button, its "menu" shows the application menu.
+func gtkDecorationLayout(layout string) string {
+	var gtkLayout buttonLayout
+	l := parseButtonLayout(layout)
+	convert := func(zone []string) []string {
+		var items []string
+		for _, b := range zone {
+			if b == "appmenu" {
+				b = "menu"
+			}
+			if !isIn(gtkLayout.left, b) && !isIn(items, b) {
+				items = append(items, b)
+			}
+		}
+		return items
+	}
+	gtkLayout.left = convert(l.left)
+	gtkLayout.right = convert(l.right)
+	return gtkLayout.String()
+}
+
+// zone returns a pointer to the zone slice by name
+func (l *buttonLayout) zone(name string) *[]string {
+	switch name {
+	case "left":
+		return &l.left
+	case "right":
+		return &l.right
+	}
+	return &l.unused
+}
+
+// move removes the button from its zone, and inserts it into the target zone before the given button,
+// or at the end if before is empty
+func (l *buttonLayout) move(button, target, before string) {
+	for _, name := range []string{"left", "right", "unused"} {
+		z := l.zone(name)
+		for i, b := range *z {
+			if b == button {
+				*z = append((*z)[:i], (*z)[i+1:]...)
+				break
+			}
+		}
+	}
+	z := l.zone(target)
+	for i, b := range *z {
+		if b == before {
+			*z = append((*z)[:i], append([]string{button}, (*z)[i:]...)...)
+			return
+		}
+	}
+	*z = append(*z, button)
+}
+
+func layoutButtonIcon(button string) string {
+	switch button {
+	case "close":
+		return "window-close-symbolic"
+	case "minimize":
+		return "window-minimize-symbolic"
+	case "maximize":
+		return "window-maximize-symbolic"
+	case "menu", "appmenu":
+		return "open-menu-symbolic"
+	case "icon":
+		return "application-x-executable-symbolic"
+	}
+	return "image-missing"
+}
+
+// setUpButtonLayoutEditor returns a widget to arrange titlebar buttons by drag and drop.
+// The onChange function is called with the new button-layout value.
+func setUpButtonLayoutEditor(layout string, onChange func(string)) *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+
+	l := parseButtonLayout(layout)
+	target, _ := gtk.TargetEntryNew("UTF8_STRING", gtk.TARGET_SAME_APP, 0)
+	targets := []gtk.TargetEntry{*target}
+
+	zones, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.PackStart(zones, false, false, 0)
+
+	entry, _ := gtk.EntryNew()
+	entry.SetTooltipText(voc["button-layout-tooltip"])
+	entry.SetText(l.String())
+	box.PackStart(entry, false, false, 0)
+
+	zoneBoxes := make(map[string]*gtk.Box)
+	var refresh func()
+
+	drop := func(data *gtk.SelectionData, zone, before string) {
+		button := data.GetText()
+		if button == "" || button == before {
+			return
+		}
+		l.move(button, zone, before)
+		// the dragged widget must outlive the drag, rebuild zones when idle
+		glib.IdleAdd(refresh)
+		entry.SetText(l.String())
+		onChange(l.String())
+	}
+
+	refresh = func() {
+		for name, zoneBox := range zoneBoxes {
+			if children := zoneBox.GetChildren(); children != nil {
+				children.Foreach(func(item interface{}) {
+					item.(*gtk.Widget).Destroy()
+				})
+			}
+			for _, button := range *l.zone(name) {
+				zone, button := name, button
+				eventBox, _ := gtk.EventBoxNew()
+				eventBox.SetTooltipText(button)
+				img, _ := gtk.ImageNewFromIconName(layoutButtonIcon(button), gtk.ICON_SIZE_LARGE_TOOLBAR)
+				img.SetProperty("margin", 6)
+				eventBox.Add(img)
+
+				eventBox.DragSourceSet(gdk.BUTTON1_MASK, targets, gdk.ACTION_MOVE)
+				eventBox.Connect("drag-data-get", func(_ *gtk.EventBox, _ *gdk.DragContext, data *gtk.SelectionData, _, _ uint) {
+					data.SetText(button)
+				})
+				eventBox.DragDestSet(gtk.DEST_DEFAULT_ALL, targets, gdk.ACTION_MOVE)
+				eventBox.Connect("drag-data-received", func(_ *gtk.EventBox, _ *gdk.DragContext, _, _ int, data *gtk.SelectionData, _, _ uint) {
+					drop(data, zone, button)
+				})
+
+				zoneBox.PackStart(eventBox, false, false, 0)
+			}
+			zoneBox.ShowAll()
+		}
+	}
+
+	for _, name := range []string{"left", "unused", "right"} {
+		zone := name
+		frame, _ := gtk.FrameNew(voc[fmt.Sprintf("buttons-%s", zone)])
+		frame.SetLabelAlign(0.5, 0.5)
+		zones.PackStart(frame, true, true, 0)
+
+		zoneBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
+		zoneBox.SetSizeRequest(-1, 36)
+		zoneBox.SetProperty("halign", gtk.ALIGN_CENTER)
+		// empty zones still need some room to drop onto
+		eventBox, _ := gtk.EventBoxNew()
+		eventBox.SetSizeRequest(120, -1)
+		eventBox.Add(zoneBox)
+		eventBox.DragDestSet(gtk.DEST_DEFAULT_ALL, targets, gdk.ACTION_MOVE)
+		eventBox.Connect("drag-data-received", func(_ *gtk.EventBox, _ *gdk.DragContext, _, _ int, data *gtk.SelectionData, _, _ uint) {
+			drop(data, zone, "")
+		})
+		frame.Add(eventBox)
+		zoneBoxes[zone] = zoneBox
+	}
+	refresh()
+
+	entry.Connect("activate", func() {
+		text, _ := entry.GetText()
+		l = parseButtonLayout(text)
+		refresh()
+		entry.SetText(l.String())
+		onChange(l.String())
+	})
+
+	return box
+}
@@ -0,0 +1,733 @@
+// Flatpak override inspector
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/nwg-piotr/nwg-look/stylepak"
+	log "github.com/sirupsen/logrus"
+)
+
+// key of filesystem conflicts, other conflicts are keyed by environment variable name
+const flatpakFilesystemKey = "filesystem"
+
+// flatpakConflict is a user override that disagrees with current nwg-look settings
+type flatpakConflict struct {
+	app     string // empty for global overrides
+	key     string
+	current string // value in effect, empty if not set
+	wanted  string // value matching nwg-look settings, empty to remove the override
+}
+
+// environment variables set by per-app theme assignments
+var flatpakAppThemeEnv = []string{"GTK_THEME", "ADW_DEBUG_COLOR_SCHEME"}
+
+// flatpakAppTheme is a theme assignment of a single flatpak app, stored in preferences.
+// Empty values follow global settings. There's no icon theme: GTK takes it from settings only, not from
+// the environment.
+type flatpakAppTheme struct {
+	GtkTheme    string   `json:"gtk-theme,omitempty"`
+	ColorScheme string   `json:"color-scheme,omitempty"`
+	Filesystems []string `json:"filesystems,omitempty"` // overrides granted by nwg-look, removed on reset
+}
+
+// flatpakCanUseTheme checks if an app assignment can make the GTK theme visible in the sandbox. User themes
+// get a filesystem override. System themes can't, unless copied to ~/.themes by the global theme install.
+func flatpakCanUseTheme(folderName string) bool {
+	return builtinGtkThemes[folderName] || userThemePath(themeKindGtk, folderName) != ""
+}
+
+// env returns environment overrides of the assignment
+func (t flatpakAppTheme) env() map[string]string {
+	env := make(map[string]string)
+	if t.GtkTheme != "" {
+		env["GTK_THEME"] = t.GtkTheme
+		if t.ColorScheme == "prefer-dark" {
+			env["GTK_THEME"] += ":dark"
+		}
+	}
+	// libadwaita ignores GTK_THEME
+	if t.ColorScheme != "" {
+		env["ADW_DEBUG_COLOR_SCHEME"] = t.ColorScheme
+	}
+	return env
+}
+
+// filesystems returns overrides that make the user-installed GTK theme of the assignment visible in the sandbox.
+// System themes are only visible to flatpak apps if installed as extensions, see flatpakCanUseTheme.
+func (t flatpakAppTheme) filesystems() []string {
+	p := userThemePath(themeKindGtk, t.GtkTheme)
+	if p == "" {
+		return nil
+	}
+	dir := filepath.Dir(p)
+	fs := dir + ":ro"
+	if rel, err := filepath.Rel(dataHome(), dir); err == nil && !strings.HasPrefix(rel, "..") {
+		fs = fmt.Sprintf("xdg-data/%s:ro", rel)
+	} else if rel, err := filepath.Rel(os.Getenv("HOME"), dir); err == nil && !strings.HasPrefix(rel, "..") {
+		fs = fmt.Sprintf("~/%s:ro", rel)
+	}
+	return []string{fs}
+}
+
+// applyFlatpakAppTheme writes overrides of the app assignment, unassigned variables are unset. Filesystem
+// overrides granted for themes no longer assigned are removed. Returns the assignment with the filesystems
+// granted by nwg-look, leaving out those the app had been given before.
+func applyFlatpakAppTheme(r stylepak.Runner, app string, t flatpakAppTheme) (flatpakAppTheme, error) {
+	env := t.env()
+	var args []string
+	for _, key := range flatpakAppThemeEnv {
+		if value, ok := env[key]; ok {
+			args = append(args, fmt.Sprintf("--env=%s=%s", key, value))
+		} else {
+			args = append(args, "--unset-env="+key)
+		}
+	}
+
+	current, err := stylepak.ShowOverride(r, app)
+	if err != nil {
+		log.Warnf("Couldn't read flatpak overrides of %s: %s", app, err)
+	}
+	var granted, stale []string
+	filesystems := t.filesystems()
+	for _, fs := range filesystems {
+		args = append(args, "--filesystem="+fs)
+		if isIn(t.Filesystems, fs) || !isIn(current.Filesystems, fs) {
+			granted = append(granted, fs)
+		}
+	}
+	for _, fs := range t.Filesystems {
+		if !isIn(filesystems, fs) {
+			stale = append(stale, fs)
+		}
+	}
+
+	if err := stylepak.RunOverride(r, app, args...); err != nil {
+		return t, err
+	}
+	t.Filesystems = granted
+	if err := stylepak.RemoveOverrideFilesystems(app, stale...); err != nil {
+		t.Filesystems = append(t.Filesystems, stale...)
+		return t, err
+	}
+	return t, nil
+}
+
+// applyFlatpakAppThemes writes overrides of all assignments stored in preferences
+func applyFlatpakAppThemes() {
+	for app, t := range preferences.FlatpakAppThemes {
+		log.Infof("Applying flatpak theme overrides of %s", app)
+		if t.GtkTheme != "" && !flatpakCanUseTheme(t.GtkTheme) {
+			log.Warnf("%s is a system theme, %s won't see it", t.GtkTheme, app)
+		}
+		t, err := applyFlatpakAppTheme(nil, app, t)
+		if err != nil {
+			log.Warnf("failed to apply flatpak theme overrides of %s: %s", app, err)
+		}
+		preferences.FlatpakAppThemes[app] = t
+	}
+}
+
+// resetFlatpakAppTheme removes the app assignment, its environment overrides and filesystem overrides it granted
+func resetFlatpakAppTheme(r stylepak.Runner, app string) error {
+	t := preferences.FlatpakAppThemes[app]
+	delete(preferences.FlatpakAppThemes, app)
+	var args []string
+	for _, key := range flatpakAppThemeEnv {
+		args = append(args, "--unset-env="+key)
+	}
+	if err := stylepak.RunOverride(r, app, args...); err != nil {
+		return err
+	}
+	return stylepak.RemoveOverrideFilesystems(app, t.Filesystems...)
+}
+
+// flatpakGtk4Filesystems give GTK4 apps access to files exported by linkGtk4Stuff: CSS in gtk-4.0,
+// and the assets directory it refers to as ../assets
+var flatpakGtk4Filesystems = []string{"xdg-config/gtk-4.0:ro", "xdg-config/assets:ro"}
+
+// flatpakColorSchemeEnv is how libadwaita apps are told the color scheme without the settings portal,
+// which not every session runs
+const flatpakColorSchemeEnv = "ADW_DEBUG_COLOR_SCHEME"
+
+// applyFlatpakGtk4Theme grants flatpak apps access to ~/.config/gtk-4.0 and ~/.config/assets, and passes
+// color-scheme from gsettings on to libadwaita apps. Overrides added are recorded in preferences, for
+// revokeFlatpakGtk4Theme; filesystems the user had granted before are left out.
+func applyFlatpakGtk4Theme(r stylepak.Runner) {
+	if !preferences.ExportGtk4Symlinks {
+		log.Warn("GTK4 files are not exported, flatpak apps will find nothing in ~/.config/gtk-4.0")
+	}
+	global, err := stylepak.ShowOverride(r, "")
+	if err != nil {
+		log.Warnf("Couldn't read global flatpak overrides: %s", err)
+	}
+	// with overrides unknown, a filesystem may have been granted by the user, and is not recorded
+	known := err == nil
+	for _, fs := range flatpakGtk4Filesystems {
+		if isIn(global.Filesystems, fs) {
+			continue
+		}
+		log.Infof("Granting flatpak apps access to %s", fs)
+		if err := stylepak.AddOverrideFilesystem(r, "", fs); err != nil {
+			log.Warnf("failed to override flatpak filesystem %s: %s", fs, err)
+			continue
+		}
+		if known && !isIn(preferences.FlatpakGtk4Filesystems, fs) {
+			preferences.FlatpakGtk4Filesystems = append(preferences.FlatpakGtk4Filesystems, fs)
+		}
+	}
+
+	// "default" leaves the choice to the app, as does no override
+	if gsettings.colorScheme == "default" {
+		unsetFlatpakColorSchemeEnv(r)
+		return
+	}
+	if global.Env[flatpakColorSchemeEnv] == gsettings.colorScheme {
+		return
+	}
+	log.Infof("Overriding flatpak %s to %s", flatpakColorSchemeEnv, gsettings.colorScheme)
+	if err := stylepak.SetOverrideEnv(r, "", flatpakColorSchemeEnv, gsettings.colorScheme); err != nil {
+		log.Warnf("failed to override flatpak %s: %s", flatpakColorSchemeEnv, err)
+		return
+	}
+	preferences.FlatpakColorSchemeEnvSet = true
+}
+
+// unsetFlatpakColorSchemeEnv removes the global color scheme override, if set by nwg-look
+func unsetFlatpakColorSchemeEnv(r stylepak.Runner) {
+	if !preferences.FlatpakColorSchemeEnvSet {
+		return
+	}
+	log.Infof("Removing flatpak %s override", flatpakColorSchemeEnv)
+	if err := stylepak.UnsetOverrideEnv(r, "", flatpakColorSchemeEnv); err != nil {
+		log.Warnf("failed to unset flatpak %s: %s", flatpakColorSchemeEnv, err)
+		return
+	}
+	preferences.FlatpakColorSchemeEnvSet = false
+}
+
+// revokeFlatpakGtk4Theme takes back global overrides applyFlatpakGtk4Theme added
+func revokeFlatpakGtk4Theme(r stylepak.Runner) {
+	if len(preferences.FlatpakGtk4Filesystems) > 0 {
+		log.Infof("Removing flatpak access to %s", strings.Join(preferences.FlatpakGtk4Filesystems, ", "))
+		if err := stylepak.RemoveOverrideFilesystems("", preferences.FlatpakGtk4Filesystems...); err != nil {
+			log.Warnf("failed to remove flatpak filesystem overrides: %s", err)
+		} else {
+			preferences.FlatpakGtk4Filesystems = nil
+		}
+	}
+	unsetFlatpakColorSchemeEnv(r)
+}
+
+// flatpakThemesDir is the directory stylepak.InstallUserTheme grants flatpak apps access to
+func flatpakThemesDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".themes")
+}
+
+// overrideHasFilesystem checks if the override grants access to the path, directly, via a parent
+// directory, or via home / host
+func overrideHasFilesystem(o stylepak.Override, path string) bool {
+	home := os.Getenv("HOME")
+	for _, fs := range o.Filesystems {
+		name, _, _ := strings.Cut(fs, ":")
+		switch {
+		case name == "home" || name == "host":
+			return true
+		case name == "xdg-data" || strings.HasPrefix(name, "xdg-data/"):
+			name = filepath.Join(dataHome(), strings.TrimPrefix(name, "xdg-data"))
+		case name == "xdg-config" || strings.HasPrefix(name, "xdg-config/"):
+			name = filepath.Join(configHome(), strings.TrimPrefix(name, "xdg-config"))
+		case strings.HasPrefix(name, "~/"):
+			name = filepath.Join(home, name[2:])
+		}
+		if path == name || strings.HasPrefix(path, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFlatpakOverrides returns global user overrides, and overrides of all apps that have any
+func loadFlatpakOverrides(r stylepak.Runner) (stylepak.Override, []stylepak.Override, error) {
+	global, err := stylepak.ShowOverride(r, "")
+	if err != nil {
+		return global, nil, err
+	}
+	ids, err := stylepak.OverrideApps()
+	if err != nil {
+		return global, nil, err
+	}
+	// apps with assigned themes, but no overrides yet
+	for id := range preferences.FlatpakAppThemes {
+		if !isIn(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	var apps []stylepak.Override
+	for _, id := range ids {
+		o, err := stylepak.ShowOverride(r, id)
+		if err != nil {
+			log.Warnf("Couldn't read flatpak overrides of %s: %s", id, err)
+			continue
+		}
+		apps = append(apps, o)
+	}
+	return global, apps, nil
+}
+
+// flatpakConflicts compares overrides with current nwg-look settings and preferences
+func flatpakConflicts(global stylepak.Override, apps []stylepak.Override) []flatpakConflict {
+	var conflicts []flatpakConflict
+
+	envs := []struct {
+		key      string
+		value    string
+		override bool
+	}{
+		{"GTK_THEME", gsettings.gtkTheme, preferences.FlatpakExportGTKThemeOverride},
+		{"ICON_THEME", gsettings.iconTheme, preferences.FlatpakExportIconThemeOverride},
+	}
+	for _, e := range envs {
+		current, ok := global.Env[e.key]
+		if e.override && current != e.value {
+			conflicts = append(conflicts, flatpakConflict{key: e.key, current: current, wanted: e.value})
+		} else if !e.override && ok && current != e.value {
+			conflicts = append(conflicts, flatpakConflict{key: e.key, current: current})
+		}
+	}
+
+	for _, o := range apps {
+		if t, ok := preferences.FlatpakAppThemes[o.App]; ok {
+			env := t.env()
+			for _, key := range flatpakAppThemeEnv {
+				if o.Env[key] != env[key] {
+					conflicts = append(conflicts, flatpakConflict{app: o.App, key: key, current: o.Env[key], wanted: env[key]})
+				}
+			}
+			continue
+		}
+		for _, e := range envs {
+			if v, ok := o.Env[e.key]; ok && v != e.value {
+				conflicts = append(conflicts, flatpakConflict{app: o.App, key: e.key, current: v})
+			}
+		}
+	}
+
+	if preferences.FlatpakInstallCurrentGTKTheme && !preferences.FlatpakThemeExtension &&
+		!overrideHasFilesystem(global, flatpakThemesDir()) {
+		conflicts = append(conflicts, flatpakConflict{key: flatpakFilesystemKey, wanted: flatpakThemesDir() + ":ro"})
+	}
+	if preferences.FlatpakInstallIconThemes && !overrideHasFilesystem(global, filepath.Join(dataHome(), "icons")) {
+		conflicts = append(conflicts, flatpakConflict{key: flatpakFilesystemKey, wanted: "xdg-data/icons:ro"})
+	}
+	if preferences.FlatpakGtk4Theme {
+		for _, fs := range flatpakGtk4Filesystems {
+			name, _, _ := strings.Cut(fs, ":")
+			if !overrideHasFilesystem(global, filepath.Join(configHome(), strings.TrimPrefix(name, "xdg-config/"))) {
+				conflicts = append(conflicts, flatpakConflict{key: flatpakFilesystemKey, wanted: fs})
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// reconcileFlatpakConflict changes the override to match nwg-look settings
+func reconcileFlatpakConflict(r stylepak.Runner, c flatpakConflict) error {
+	switch {
+	case c.key == flatpakFilesystemKey:
+		return stylepak.AddOverrideFilesystem(r, c.app, c.wanted)
+	case c.wanted == "":
+		return stylepak.UnsetOverrideEnv(r, c.app, c.key)
+	default:
+		return stylepak.SetOverrideEnv(r, c.app, c.key, c.wanted)
+	}
+}
+
+func (c flatpakConflict) description() string {
+	if c.wanted == "" {
+		return voc["flatpak-conflict-remove"]
+	}
+	return fmt.Sprintf("%s: %s", voc["flatpak-conflict-expected"], c.wanted)
+}
+
+func setUpFlatpakSettingsForm() *gtk.Frame {
+	frame, _ := gtk.FrameNew(fmt.Sprintf("  %s  ", voc["flatpak-settings"]))
+	frame.SetLabelAlign(0.5, 0.5)
+	frame.SetProperty("margin", 6)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetProperty("margin", 6)
+	frame.Add(box)
+
+	lbl, _ := gtk.LabelNew("")
+	lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["flatpak-app-themes"]))
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	box.PackStart(lbl, false, false, 0)
+
+	var refresh func()
+	box.PackStart(setUpFlatpakAppThemes(func() {
+		refresh()
+	}), false, false, 0)
+
+	lbl, _ = gtk.LabelNew("")
+	lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["flatpak-overrides"]))
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	box.PackStart(lbl, false, false, 0)
+
+	hBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.PackStart(hBox, false, false, 0)
+
+	statusLabel, _ := gtk.LabelNew("")
+	statusLabel.SetLineWrap(true)
+	statusLabel.SetProperty("halign", gtk.ALIGN_START)
+	hBox.PackStart(statusLabel, true, true, 0)
+
+	btnReconcile, _ := gtk.ButtonNewWithLabel(voc["flatpak-reconcile-all"])
+	btnReconcile.SetTooltipText(voc["flatpak-reconcile-all-tooltip"])
+	hBox.PackEnd(btnReconcile, false, false, 0)
+
+	btnRefresh, _ := gtk.ButtonNewWithLabel(voc["refresh"])
+	hBox.PackEnd(btnRefresh, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	scrolled.SetProperty("hexpand", true)
+	scrolled.SetProperty("vexpand", true)
+	box.PackStart(scrolled, true, true, 0)
+	// an explicit viewport, as the grid is replaced on refresh
+	vp, _ := gtk.ViewportNew(nil, nil)
+	scrolled.Add(vp)
+
+	runner := stylepak.ExecRunner{}
+	var g *gtk.Grid
+	var conflicts []flatpakConflict
+
+	reconcile := func(toFix []flatpakConflict) {
+		var errs []string
+		for _, c := range toFix {
+			if err := reconcileFlatpakConflict(runner, c); err != nil {
+				log.Warn(err)
+				errs = append(errs, err.Error())
+			}
+		}
+		refresh()
+		if len(errs) > 0 {
+			statusLabel.SetText(strings.Join(errs, "\n"))
+		}
+	}
+
+	refresh = func() {
+		if g != nil {
+			g.Destroy()
+		}
+		g, _ = gtk.GridNew()
+		g.SetRowSpacing(6)
+		g.SetColumnSpacing(12)
+		g.SetProperty("margin", 6)
+		vp.Add(g)
+		defer g.ShowAll()
+
+		global, apps, err := loadFlatpakOverrides(runner)
+		if err != nil {
+			log.Warnf("Couldn't read flatpak overrides: %s", err)
+			statusLabel.SetText(fmt.Sprintf("%s: %s", voc["flatpak-overrides-error"], err))
+			btnReconcile.SetSensitive(false)
+			return
+		}
+		conflicts = flatpakConflicts(global, apps)
+		btnReconcile.SetSensitive(len(conflicts) > 0)
+		if len(conflicts) > 0 {
+			statusLabel.SetText(fmt.Sprintf("%s: %v", voc["flatpak-conflicts"], len(conflicts)))
+		} else {
+			statusLabel.SetText(voc["flatpak-no-conflicts"])
+		}
+
+		for i, header := range []string{voc["flatpak-app"], voc["flatpak-override"], voc["value"], ""} {
+			lbl, _ := gtk.LabelNew("")
+			lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", header))
+			lbl.SetProperty("halign", gtk.ALIGN_START)
+			g.Attach(lbl, i, 0, 1, 1)
+		}
+
+		row := 1
+		addRow := func(app, key, value string, conflict *flatpakConflict) {
+			scope := app
+			if scope == "" {
+				scope = voc["flatpak-all-apps"]
+			}
+			for i, text := range []string{scope, key, value} {
+				lbl, _ := gtk.LabelNew(text)
+				lbl.SetProperty("halign", gtk.ALIGN_START)
+				lbl.SetSelectable(true)
+				g.Attach(lbl, i, row, 1, 1)
+			}
+			if conflict != nil {
+				c := *conflict
+				status, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+				img, _ := gtk.ImageNewFromIconName("dialog-warning-symbolic", gtk.ICON_SIZE_MENU)
+				status.PackStart(img, false, false, 0)
+				lbl, _ := gtk.LabelNew(c.description())
+				status.PackStart(lbl, false, false, 0)
+				btn, _ := gtk.ButtonNewWithLabel(voc["flatpak-reconcile"])
+				btn.Connect("clicked", func() {
+					reconcile([]flatpakConflict{c})
+				})
+				status.PackStart(btn, false, false, 0)
+				g.Attach(status, 3, row, 1, 1)
+			}
+			row++
+		}
+
+		for _, o := range append([]stylepak.Override{global}, apps...) {
+			var keys []string
+			for key := range o.Env {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				var conflict *flatpakConflict
+				for i := range conflicts {
+					if conflicts[i].app == o.App && conflicts[i].key == key {
+						conflict = &conflicts[i]
+					}
+				}
+				addRow(o.App, key, o.Env[key], conflict)
+			}
+			for _, fs := range o.Filesystems {
+				addRow(o.App, flatpakFilesystemKey, fs, nil)
+			}
+			// overrides nwg-look expects, but are not set at all
+			for i := range conflicts {
+				_, set := o.Env[conflicts[i].key]
+				if conflicts[i].app == o.App && (conflicts[i].key == flatpakFilesystemKey || !set) {
+					addRow(o.App, conflicts[i].key, "", &conflicts[i])
+				}
+			}
+		}
+
+		if row == 1 {
+			lbl, _ := gtk.LabelNew(voc["flatpak-no-overrides"])
+			lbl.SetProperty("halign", gtk.ALIGN_START)
+			g.Attach(lbl, 0, row, 4, 1)
+		}
+	}
+
+	btnRefresh.Connect("clicked", func() {
+		refresh()
+	})
+	btnReconcile.Connect("clicked", func() {
+		reconcile(conflicts)
+	})
+	refresh()
+
+	return frame
+}
+
+// setUpFlatpakAppThemes returns a widget to assign themes to flatpak apps. Assignments are written on Apply,
+// except for reset, which takes effect right away and calls the onReset function.
+func setUpFlatpakAppThemes(onReset func()) *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+
+	apps, err := stylepak.InstalledApps(nil)
+	if err != nil {
+		log.Warnf("Couldn't list flatpak apps: %s", err)
+	}
+	appNames := make(map[string]string)
+	for _, a := range apps {
+		appNames[a.ID] = a.Name
+	}
+
+	gtkThemes, _ := getThemeNames()
+
+	// combo with the "follow global settings" entry first, options are [id, label] pairs
+	newCombo := func(options [][2]string, active string, onChange func(string)) *gtk.ComboBoxText {
+		combo, _ := gtk.ComboBoxTextNew()
+		combo.Append("", voc["flatpak-follow-global"])
+		found := active == ""
+		for _, o := range options {
+			combo.Append(o[0], o[1])
+			found = found || o[0] == active
+		}
+		if !found {
+			combo.Append(active, active)
+		}
+		combo.SetActiveID(active)
+		combo.Connect("changed", func() {
+			onChange(combo.GetActiveID())
+		})
+		return combo
+	}
+
+	// system themes are not offered, flatpak apps can't see them
+	var gtkOptions [][2]string
+	for _, name := range gtkThemes {
+		if flatpakCanUseTheme(name) {
+			gtkOptions = append(gtkOptions, [2]string{name, name})
+		}
+	}
+	schemeOptions := [][2]string{{"prefer-dark", voc["prefer-dark"]}, {"prefer-light", voc["prefer-light"]}}
+
+	var g *gtk.Grid
+	var refresh func()
+	refresh = func() {
+		if g != nil {
+			g.Destroy()
+		}
+		g, _ = gtk.GridNew()
+		g.SetRowSpacing(6)
+		g.SetColumnSpacing(12)
+		box.PackStart(g, false, false, 0)
+
+		for i, header := range []string{voc["flatpak-app"], voc["gtk-theme"], voc["color-scheme"]} {
+			lbl, _ := gtk.LabelNew("")
+			lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", header))
+			lbl.SetProperty("halign", gtk.ALIGN_START)
+			g.Attach(lbl, i, 0, 1, 1)
+		}
+
+		var ids []string
+		for id := range preferences.FlatpakAppThemes {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		row := 1
+		for _, id := range ids {
+			id := id
+			name := appNames[id]
+			if name == "" {
+				name = id
+			}
+			lbl, _ := gtk.LabelNew(name)
+			lbl.SetTooltipText(id)
+			lbl.SetProperty("halign", gtk.ALIGN_START)
+			g.Attach(lbl, 0, row, 1, 1)
+
+			t := preferences.FlatpakAppThemes[id]
+			gtkCombo := newCombo(gtkOptions, t.GtkTheme, func(value string) {
+				t := preferences.FlatpakAppThemes[id]
+				t.GtkTheme = value
+				preferences.FlatpakAppThemes[id] = t
+			})
+			gtkCombo.SetTooltipText(voc["flatpak-user-themes-only"])
+			g.Attach(gtkCombo, 1, row, 1, 1)
+			g.Attach(newCombo(schemeOptions, t.ColorScheme, func(value string) {
+				t := preferences.FlatpakAppThemes[id]
+				t.ColorScheme = value
+				preferences.FlatpakAppThemes[id] = t
+			}), 2, row, 1, 1)
+
+			btn, _ := gtk.ButtonNewWithLabel(voc["flatpak-reset"])
+			btn.SetTooltipText(voc["flatpak-reset-tooltip"])
+			btn.Connect("clicked", func() {
+				if err := resetFlatpakAppTheme(nil, id); err != nil {
+					log.Warnf("failed to reset flatpak theme overrides of %s: %s", id, err)
+				}
+				savePreferences()
+				refresh()
+				onReset()
+			})
+			g.Attach(btn, 3, row, 1, 1)
+			row++
+		}
+
+		appCombo, _ := gtk.ComboBoxTextNew()
+		for _, a := range apps {
+			if _, ok := preferences.FlatpakAppThemes[a.ID]; !ok {
+				appCombo.Append(a.ID, a.Name)
+			}
+		}
+		g.Attach(appCombo, 0, row, 1, 1)
+
+		btnAdd, _ := gtk.ButtonNewWithLabel(voc["flatpak-add-app"])
+		btnAdd.Connect("clicked", func() {
+			id := appCombo.GetActiveID()
+			if id == "" {
+				return
+			}
+			if preferences.FlatpakAppThemes == nil {
+				preferences.FlatpakAppThemes = make(map[string]flatpakAppTheme)
+			}
+			preferences.FlatpakAppThemes[id] = flatpakAppTheme{}
+			refresh()
+		})
+		g.Attach(btnAdd, 1, row, 1, 1)
+
+		g.ShowAll()
+	}
+	refresh()
+
+	return box
+}
+
+// flatpakCommand handles `nwg-look flatpak list|clean|reinstall`, for theme copies made for flatpak apps
+func flatpakCommand(action string) int {
+	themes, err := stylepak.ListManagedThemes()
+	if err != nil {
+		fmt.Printf("Couldn't list managed themes: %s\n", err)
+		return 1
+	}
+
+	switch action {
+	case "list":
+		if len(themes) == 0 {
+			fmt.Println("No theme copies managed by nwg-look")
+			return 0
+		}
+		for _, t := range themes {
+			source := t.Source
+			if source == "" {
+				source = "unknown source"
+			}
+			fmt.Printf("%-6s %s\t%s (%s)\n", t.Kind, t.Name, t.Path, source)
+		}
+	case "clean":
+		if len(themes) == 0 {
+			fmt.Println("Nothing to clean")
+			return 0
+		}
+		for _, t := range themes {
+			fmt.Println(t.Path)
+		}
+		fmt.Print("Remove the directories listed above, and flatpak access to them? y/N ")
+		var input string
+		fmt.Scanln(&input)
+		if strings.ToUpper(input) != "Y" {
+			return 0
+		}
+		for _, t := range themes {
+			if err := stylepak.RemoveManagedTheme(t); err != nil {
+				fmt.Printf("Couldn't remove %s: %s\n", t.Path, err)
+				return 1
+			}
+		}
+		if err := stylepak.RemoveManagedThemeAccess(); err != nil {
+			fmt.Printf("Couldn't remove flatpak filesystem overrides: %s\n", err)
+			return 1
+		}
+		fmt.Println("Removed flatpak access to the theme copies")
+	case "reinstall":
+		status := 0
+		for _, t := range themes {
+			if err := stylepak.ReinstallManagedTheme(t); err != nil {
+				fmt.Printf("Couldn't reinstall %s: %s\n", t.Path, err)
+				status = 1
+				continue
+			}
+			fmt.Printf("Reinstalled %s\n", t.Path)
+		}
+		return status
+	default:
+		fmt.Println("Usage: nwg-look flatpak list|clean|reinstall")
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,168 @@
+// fontconfig exporter
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type fontconfigEdit struct {
+	Name  string `xml:"name,attr"`
+	Mode  string `xml:"mode,attr"`
+	Bool  string `xml:"bool,omitempty"`
+	Const string `xml:"const,omitempty"`
+}
+
+type fontconfigMatch struct {
+	Target string           `xml:"target,attr"`
+	Edits  []fontconfigEdit `xml:"edit"`
+}
+
+type fontconfigAlias struct {
+	Binding string   `xml:"binding,attr"`
+	Family  string   `xml:"family"`
+	Prefer  []string `xml:"prefer>family"`
+}
+
+type fontconfigDoc struct {
+	XMLName xml.Name          `xml:"fontconfig"`
+	Match   fontconfigMatch   `xml:"match"`
+	Aliases []fontconfigAlias `xml:"alias"`
+}
+
+// words pango.FontDescriptionFromString takes for style, variant, weight, stretch or gravity after families
+var pangoStyleWords = strings.Fields(`normal roman oblique italic
+	small-caps all-small-caps petite-caps all-petite-caps unicase title-caps
+	thin ultra-light extra-light light semi-light demi-light book regular medium
+	semi-bold demi-bold bold ultra-bold extra-bold heavy black ultra-heavy extra-heavy
+	ultra-condensed extra-condensed condensed semi-condensed semi-expanded expanded extra-expanded ultra-expanded
+	not-rotated south upside-down north rotated-left east rotated-right west`)
+
+// pangoFontFamilies returns families of a Pango font description, e.g. "Noto Sans Bold 10" gives [Noto Sans].
+// Pango itself is not used, so that the exporter works without a display.
+func pangoFontFamilies(desc string) []string {
+	fields := strings.Fields(desc)
+	for len(fields) > 0 {
+		last := strings.ToLower(fields[len(fields)-1])
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(last, "px"), 64); err != nil && !isIn(pangoStyleWords, last) {
+			break
+		}
+		fields = fields[:len(fields)-1]
+	}
+	var families []string
+	for _, f := range strings.Split(strings.Join(fields, " "), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			families = append(families, f)
+		}
+	}
+	return families
+}
+
+func fontconfigFile() string {
+	return filepath.Join(configHome(), "fontconfig/conf.d/50-nwg-look.conf")
+}
+
+// fontconfigXML returns the fontconfig document matching gsettings font rendering values. With the
+// FontconfigFamilies preference, sans-serif and monospace are also aliased to the GTK fonts.
+func fontconfigXML() ([]byte, error) {
+	hinting := "true"
+	if gsettings.fontHinting == "none" {
+		hinting = "false"
+	}
+
+	var hintStyle string
+	switch gsettings.fontHinting {
+	case "slight":
+		hintStyle = "hintslight"
+	case "medium":
+		hintStyle = "hintmedium"
+	case "full":
+		hintStyle = "hintfull"
+	default:
+		hintStyle = "hintnone"
+	}
+
+	antialias := "true"
+	if gsettings.fontAntialiasing == "none" {
+		antialias = "false"
+	}
+
+	rgba, lcdFilter := "none", "lcdnone"
+	if gsettings.fontAntialiasing == "rgba" {
+		rgba, lcdFilter = gsettings.fontRgbaOrder, "lcddefault"
+	}
+
+	doc := fontconfigDoc{
+		Match: fontconfigMatch{
+			Target: "font",
+			Edits: []fontconfigEdit{
+				{Name: "hinting", Mode: "assign", Bool: hinting},
+				{Name: "hintstyle", Mode: "assign", Const: hintStyle},
+				{Name: "antialias", Mode: "assign", Bool: antialias},
+				{Name: "rgba", Mode: "assign", Const: rgba},
+				{Name: "lcdfilter", Mode: "assign", Const: lcdFilter},
+			},
+		},
+	}
+
+	// opt-in, as the aliases rebind generic families for every fontconfig client
+	if !preferences.FontconfigFamilies {
+		return marshalFontconfig(doc)
+	}
+	for _, a := range []struct{ generic, font string }{
+		{"sans-serif", gsettings.fontName},
+		{"monospace", gsettings.monospaceFontName},
+	} {
+		if families := pangoFontFamilies(a.font); len(families) > 0 {
+			doc.Aliases = append(doc.Aliases, fontconfigAlias{Binding: "same", Family: a.generic, Prefer: families})
+		}
+	}
+
+	return marshalFontconfig(doc)
+}
+
+func marshalFontconfig(doc fontconfigDoc) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	header := []string{
+		`<?xml version="1.0"?>`,
+		`<!DOCTYPE fontconfig SYSTEM "urn:fontconfig:fonts.dtd">`,
+		`<!-- Generated by nwg-look, do not edit this file. -->`,
+	}
+	return []byte(strings.Join(header, "\n") + "\n" + string(body) + "\n"), nil
+}
+
+func saveFontconfig() {
+	configFile := fontconfigFile()
+	makeDir(filepath.Dir(configFile))
+	log.Infof(">>> Exporting %s", configFile)
+
+	data, err := fontconfigXML()
+	if err != nil {
+		log.Warnf("Couldn't generate fontconfig XML: %s", err)
+		return
+	}
+	log.Debug(string(data))
+
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		log.Warnf("Couldn't write %s: %s", configFile, err)
+	}
+}
+
+func clearFontconfig() {
+	configFile := fontconfigFile()
+	if pathExists(configFile) {
+		log.Infof("Removing '%s'", configFile)
+		if err := os.Remove(configFile); err != nil {
+			log.Warnf("Couldn't remove '%s': %s", configFile, err)
+		}
+	}
+}
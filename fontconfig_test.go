@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFontconfigXML(t *testing.T) {
+	saved := gsettings
+	defer func() { gsettings = saved }()
+
+	tests := []struct {
+		hinting, antialiasing, rgbaOrder string
+		want                             map[string]string
+	}{
+		{"slight", "rgba", "rgb", map[string]string{
+			"hinting": "true", "hintstyle": "hintslight", "antialias": "true", "rgba": "rgb", "lcdfilter": "lcddefault",
+		}},
+		{"full", "grayscale", "bgr", map[string]string{
+			"hinting": "true", "hintstyle": "hintfull", "antialias": "true", "rgba": "none", "lcdfilter": "lcdnone",
+		}},
+		{"none", "none", "rgb", map[string]string{
+			"hinting": "false", "hintstyle": "hintnone", "antialias": "false", "rgba": "none", "lcdfilter": "lcdnone",
+		}},
+	}
+
+	for _, tt := range tests {
+		gsettings = gsettingsNewWithDefaults()
+		gsettings.fontHinting = tt.hinting
+		gsettings.fontAntialiasing = tt.antialiasing
+		gsettings.fontRgbaOrder = tt.rgbaOrder
+
+		data, err := fontconfigXML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `<!DOCTYPE fontconfig SYSTEM "urn:fontconfig:fonts.dtd">`) {
+			t.Errorf("%s/%s: no fontconfig DOCTYPE:\n%s", tt.hinting, tt.antialiasing, data)
+		}
+
+		var doc fontconfigDoc
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("%s/%s: invalid XML: %s\n%s", tt.hinting, tt.antialiasing, err, data)
+		}
+		if doc.Match.Target != "font" {
+			t.Errorf("match target = %q, want font", doc.Match.Target)
+		}
+		got := make(map[string]string)
+		for _, e := range doc.Match.Edits {
+			if e.Mode != "assign" {
+				t.Errorf("%s: mode = %q, want assign", e.Name, e.Mode)
+			}
+			got[e.Name] = e.Bool + e.Const
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s/%s: edits = %v, want %v", tt.hinting, tt.antialiasing, got, tt.want)
+		}
+		if len(doc.Aliases) > 0 {
+			t.Errorf("%s/%s: aliases without the preference: %+v", tt.hinting, tt.antialiasing, doc.Aliases)
+		}
+	}
+}
+
+func TestFontconfigXMLFamilies(t *testing.T) {
+	saved, savedPreferences := gsettings, preferences
+	defer func() { gsettings, preferences = saved, savedPreferences }()
+
+	preferences = programSettingsNewWithDefaults()
+	preferences.FontconfigFamilies = true
+	gsettings = gsettingsNewWithDefaults()
+	gsettings.fontName = "Fira & Friends Bold 11"
+	gsettings.monospaceFontName = "Mono <Nerd> 10"
+
+	data, err := fontconfigXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range []string{"Fira & Friends", "Mono <Nerd>"} {
+		if strings.Contains(string(data), raw) {
+			t.Errorf("%q is not escaped:\n%s", raw, data)
+		}
+	}
+
+	var doc fontconfigDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid XML: %s\n%s", err, data)
+	}
+	want := []fontconfigAlias{
+		{Binding: "same", Family: "sans-serif", Prefer: []string{"Fira & Friends"}},
+		{Binding: "same", Family: "monospace", Prefer: []string{"Mono <Nerd>"}},
+	}
+	if !reflect.DeepEqual(doc.Aliases, want) {
+		t.Errorf("aliases = %+v, want %+v", doc.Aliases, want)
+	}
+}
+
+func TestPangoFontFamilies(t *testing.T) {
+	tests := map[string][]string{
+		"Cantarell 11":                  {"Cantarell"},
+		"Noto Sans Semi-Bold Italic 10": {"Noto Sans"},
+		"DejaVu Sans Mono,Monospace 9":  {"DejaVu Sans Mono", "Monospace"},
+		"Sans 12px":                     {"Sans"},
+		"Bold 10":                       nil,
+	}
+	for desc, want := range tests {
+		if got := pangoFontFamilies(desc); !reflect.DeepEqual(got, want) {
+			t.Errorf("pangoFontFamilies(%q) = %q, want %q", desc, got, want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// font rendering comparison preview
+package main
+
+/*
+#cgo pkg-config: pangocairo
+#include <pango/pangocairo.h>
+
+static void set_layout_font_options(PangoLayout *layout, int antialias, int subpixel_order, int hint_style) {
+	cairo_font_options_t *options = cairo_font_options_create();
+	cairo_font_options_set_antialias(options, antialias);
+	cairo_font_options_set_subpixel_order(options, subpixel_order);
+	cairo_font_options_set_hint_style(options, hint_style);
+	cairo_font_options_set_hint_metrics(options,
+		hint_style == CAIRO_HINT_STYLE_NONE ? CAIRO_HINT_METRICS_OFF : CAIRO_HINT_METRICS_ON);
+	pango_cairo_context_set_font_options(pango_layout_get_context(layout), options);
+	cairo_font_options_destroy(options);
+	pango_layout_context_changed(layout);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+const (
+	fontPreviewCellWidth  = 180
+	fontPreviewCellHeight = 64
+)
+
+// fontRendering is a single hinting × antialiasing × subpixel order combination
+type fontRendering struct {
+	hinting      string
+	antialiasing string
+	rgbaOrder    string
+}
+
+var fontPreviewHintings = []string{"none", "slight", "medium", "full"}
+
+var fontPreviewAntialiasings = []fontRendering{
+	{antialiasing: "none"},
+	{antialiasing: "grayscale"},
+	{antialiasing: "rgba", rgbaOrder: "rgb"},
+	{antialiasing: "rgba", rgbaOrder: "bgr"},
+	{antialiasing: "rgba", rgbaOrder: "vrgb"},
+	{antialiasing: "rgba", rgbaOrder: "vbgr"},
+}
+
+func (r fontRendering) isCurrent() bool {
+	return r.hinting == gsettings.fontHinting && r.antialiasing == gsettings.fontAntialiasing &&
+		(r.antialiasing != "rgba" || r.rgbaOrder == gsettings.fontRgbaOrder)
+}
+
+func (r fontRendering) label() string {
+	if r.antialiasing == "rgba" {
+		return fmt.Sprintf("rgba %s", r.rgbaOrder)
+	}
+	return voc[r.antialiasing]
+}
+
+// setLayoutFontOptions makes the layout render with the given combination, regardless of screen settings
+func setLayoutFontOptions(layout *pango.Layout, r fontRendering) {
+	hintStyle := cairo.HINT_STYLE_NONE
+	switch r.hinting {
+	case "slight":
+		hintStyle = cairo.HINT_STYLE_SLIGHT
+	case "medium":
+		hintStyle = cairo.HINT_STYLE_MEDIUM
+	case "full":
+		hintStyle = cairo.HINT_STYLE_FULL
+	}
+
+	antialias, subpixelOrder := cairo.ANTIALIAS_NONE, cairo.SUBPIXEL_ORDER_DEFAULT
+	switch r.antialiasing {
+	case "grayscale":
+		antialias = cairo.ANTIALIAS_GRAY
+	case "rgba":
+		antialias = cairo.ANTIALIAS_SUBPIXEL
+		switch r.rgbaOrder {
+		case "bgr":
+			subpixelOrder = cairo.SUBPIXEL_ORDER_BGR
+		case "vrgb":
+			subpixelOrder = cairo.SUBPIXEL_ORDER_VRGB
+		case "vbgr":
+			subpixelOrder = cairo.SUBPIXEL_ORDER_VBGR
+		default:
+			subpixelOrder = cairo.SUBPIXEL_ORDER_RGB
+		}
+	}
+
+	C.set_layout_font_options((*C.PangoLayout)(unsafe.Pointer(layout.Native())),
+		C.int(antialias), C.int(subpixelOrder), C.int(hintStyle))
+}
+
+// setUpFontRenderingPreview returns a grid rendering the sample text with every font rendering combination.
+// The onSelect function is called when user clicks a cell. The returned function redraws all cells.
+func setUpFontRenderingPreview(onSelect func(fontRendering)) (*gtk.Box, func()) {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+
+	var cells []*gtk.DrawingArea
+	redraw := func() {
+		for _, da := range cells {
+			da.QueueDraw()
+		}
+	}
+
+	hBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.PackStart(hBox, false, false, 0)
+
+	lbl, _ := gtk.LabelNew(fmt.Sprintf("%s:", voc["sample-text"]))
+	hBox.PackStart(lbl, false, false, 0)
+
+	entry, _ := gtk.EntryNew()
+	entry.SetText(voc["font-preview-sample"])
+	entry.Connect("changed", redraw)
+	hBox.PackStart(entry, true, true, 0)
+
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["size"]))
+	hBox.PackStart(lbl, false, false, 0)
+
+	size := 10.0
+	if desc := pango.FontDescriptionFromString(gsettings.fontName); desc.GetSize() > 0 {
+		size = float64(desc.GetSize()) / float64(pango.SCALE)
+	}
+	sbSize, _ := gtk.SpinButtonNewWithRange(6, 32, 0.5)
+	sbSize.SetValue(size)
+	sbSize.Connect("value-changed", redraw)
+	hBox.PackStart(sbSize, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	scrolled.SetProperty("hexpand", true)
+	scrolled.SetProperty("vexpand", true)
+	scrolled.SetSizeRequest(-1, 220)
+	box.PackStart(scrolled, true, true, 0)
+
+	g, _ := gtk.GridNew()
+	g.SetRowSpacing(6)
+	g.SetColumnSpacing(6)
+	g.SetProperty("margin", 6)
+	scrolled.Add(g)
+
+	for col, aa := range fontPreviewAntialiasings {
+		lbl, _ := gtk.LabelNew(aa.label())
+		g.Attach(lbl, col+1, 0, 1, 1)
+	}
+
+	for row, hinting := range fontPreviewHintings {
+		lbl, _ := gtk.LabelNew(voc[hinting])
+		lbl.SetProperty("halign", gtk.ALIGN_END)
+		g.Attach(lbl, 0, row+1, 1, 1)
+
+		for col, aa := range fontPreviewAntialiasings {
+			r := fontRendering{hinting: hinting, antialiasing: aa.antialiasing, rgbaOrder: aa.rgbaOrder}
+
+			eventBox, _ := gtk.EventBoxNew()
+			eventBox.SetTooltipText(fmt.Sprintf("%s: %s, %s: %s", voc["font-hinting"], voc[hinting],
+				voc["font-antialiasing"], aa.label()))
+			da, _ := gtk.DrawingAreaNew()
+			da.SetSizeRequest(fontPreviewCellWidth, fontPreviewCellHeight)
+			eventBox.Add(da)
+			cells = append(cells, da)
+
+			da.Connect("draw", func(da *gtk.DrawingArea, cr *cairo.Context) bool {
+				width := float64(da.GetAllocatedWidth())
+				height := float64(da.GetAllocatedHeight())
+
+				cr.SetSourceRGB(1, 1, 1)
+				cr.Rectangle(0, 0, width, height)
+				cr.Fill()
+
+				if r.isCurrent() {
+					cr.SetSourceRGB(0.21, 0.52, 0.89)
+					cr.SetLineWidth(4)
+					cr.Rectangle(0, 0, width, height)
+					cr.Stroke()
+				}
+
+				text, _ := entry.GetText()
+				desc := pango.FontDescriptionFromString(gsettings.fontName)
+				desc.SetSize(int(sbSize.GetValue() * gsettings.textScalingFactor * float64(pango.SCALE)))
+
+				layout := pango.CairoCreateLayout(cr)
+				setLayoutFontOptions(layout, r)
+				layout.SetFontDescription(desc)
+				layout.SetWidth(int((width - 12) * float64(pango.SCALE)))
+				layout.SetWrap(pango.WRAP_WORD)
+				layout.SetText(text, -1)
+
+				cr.SetSourceRGB(0, 0, 0)
+				cr.MoveTo(6, 6)
+				pango.CairoShowLayout(cr, layout)
+				return false
+			})
+
+			eventBox.Connect("button-press-event", func(_ *gtk.EventBox, event *gdk.Event) bool {
+				btnEvent := gdk.EventButtonNewFromEvent(event)
+				if btnEvent.Button() == gdk.BUTTON_PRIMARY {
+					onSelect(r)
+					redraw()
+					return true
+				}
+				return false
+			})
+
+			g.Attach(eventBox, col+1, row+1, 1, 1)
+		}
+	}
+
+	return box, redraw
+}
@@ -0,0 +1,276 @@
+// widget gallery tabs of the widget style preview
+package main
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+func addStyleClass(widget *gtk.Widget, class string) {
+	styleContext, _ := widget.GetStyleContext()
+	styleContext.AddClass(class)
+}
+
+func newGalleryGrid() *gtk.Grid {
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(6)
+	grid.SetColumnSpacing(12)
+	grid.SetProperty("margin", 6)
+	return grid
+}
+
+// setUpGalleryControls returns the headerbar with window controls, buttons and entries, in their normal,
+// insensitive, error and action states
+func setUpGalleryControls() *gtk.Grid {
+	grid := newGalleryGrid()
+
+	headerBar, _ := gtk.HeaderBarNew()
+	headerBar.SetTitle("nwg-look")
+	headerBar.SetSubtitle(voc["widget-style-preview"])
+	headerBar.SetShowCloseButton(true)
+	headerBar.SetDecorationLayout(gtkDecorationLayout(gsettings.buttonLayout))
+	grid.Attach(headerBar, 0, 0, 3, 1)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.SetProperty("hexpand", true)
+	grid.Attach(box, 0, 1, 3, 1)
+
+	for _, icon := range []string{"go-previous", "go-next", "process-stop"} {
+		btn, _ := gtk.ButtonNewFromIconName(icon, gtk.ICON_SIZE_BUTTON)
+		btn.SetProperty("can-focus", false)
+		box.PackStart(btn, false, false, 0)
+	}
+
+	entry, _ := gtk.EntryNew()
+	entry.SetProperty("can-focus", false)
+	entry.SetPlaceholderText(voc["entry"])
+	box.PackStart(entry, true, true, 0)
+
+	checkButton, _ := gtk.CheckButtonNew()
+	checkButton.SetProperty("can-focus", false)
+	checkButton.SetLabel(voc["check-button"])
+	checkButton.SetActive(true)
+	grid.Attach(checkButton, 0, 2, 1, 1)
+
+	radioButton, _ := gtk.RadioButtonNew(nil)
+	radioButton.SetProperty("can-focus", false)
+	radioButton.SetLabel(voc["radio-button"])
+	grid.Attach(radioButton, 1, 2, 1, 1)
+
+	sw, _ := gtk.SwitchNew()
+	sw.SetProperty("can-focus", false)
+	sw.SetActive(true)
+	sw.SetProperty("halign", gtk.ALIGN_START)
+	grid.Attach(sw, 2, 2, 1, 1)
+
+	checkButton, _ = gtk.CheckButtonNew()
+	checkButton.SetLabel(voc["insensitive"])
+	checkButton.SetActive(true)
+	checkButton.SetSensitive(false)
+	grid.Attach(checkButton, 0, 3, 1, 1)
+
+	radioButton, _ = gtk.RadioButtonNew(nil)
+	radioButton.SetLabel(voc["insensitive"])
+	radioButton.SetSensitive(false)
+	grid.Attach(radioButton, 1, 3, 1, 1)
+
+	sw, _ = gtk.SwitchNew()
+	sw.SetSensitive(false)
+	sw.SetProperty("halign", gtk.ALIGN_START)
+	grid.Attach(sw, 2, 3, 1, 1)
+
+	buttons := []struct {
+		label     string
+		class     string
+		sensitive bool
+	}{
+		{voc["button"], "", true},
+		{voc["suggested-action"], "suggested-action", true},
+		{voc["destructive-action"], "destructive-action", true},
+		{voc["insensitive"], "", false},
+		{voc["suggested-action"], "suggested-action", false},
+		{voc["destructive-action"], "destructive-action", false},
+	}
+	for i, b := range buttons {
+		btn, _ := gtk.ButtonNewWithLabel(b.label)
+		btn.SetProperty("can-focus", false)
+		btn.SetSensitive(b.sensitive)
+		if b.class != "" {
+			addStyleClass(&btn.Widget, b.class)
+		}
+		grid.Attach(btn, i%3, 4+i/3, 1, 1)
+	}
+
+	spinButton, _ := gtk.SpinButtonNewWithRange(0, 1000, 10)
+	spinButton.SetProperty("can-focus", false)
+	grid.Attach(spinButton, 0, 6, 1, 1)
+
+	entry, _ = gtk.EntryNew()
+	entry.SetProperty("can-focus", false)
+	entry.SetText(voc["error"])
+	addStyleClass(&entry.Widget, "error")
+	grid.Attach(entry, 1, 6, 1, 1)
+
+	entry, _ = gtk.EntryNew()
+	entry.SetText(voc["insensitive"])
+	entry.SetSensitive(false)
+	grid.Attach(entry, 2, 6, 1, 1)
+
+	combo, _ := gtk.ComboBoxTextNew()
+	combo.Append("entry #1", fmt.Sprintf("%s 1", voc["entry"]))
+	combo.Append("entry #2", fmt.Sprintf("%s 2", voc["entry"]))
+	combo.SetActive(0)
+	combo.SetProperty("can-focus", false)
+	grid.Attach(combo, 0, 7, 1, 1)
+
+	return grid
+}
+
+// setUpGalleryRanges returns scales, progress and level bars
+func setUpGalleryRanges() *gtk.Grid {
+	grid := newGalleryGrid()
+
+	scale, _ := gtk.ScaleNewWithRange(gtk.ORIENTATION_HORIZONTAL, 0, 100, 1)
+	scale.SetProperty("can-focus", false)
+	scale.SetProperty("hexpand", true)
+	scale.SetDrawValue(true)
+	scale.SetValue(50)
+	grid.Attach(scale, 0, 0, 1, 1)
+
+	scale, _ = gtk.ScaleNewWithRange(gtk.ORIENTATION_HORIZONTAL, 0, 100, 1)
+	scale.SetDrawValue(true)
+	scale.SetValue(30)
+	scale.SetSensitive(false)
+	grid.Attach(scale, 0, 1, 1, 1)
+
+	progressBar, _ := gtk.ProgressBarNew()
+	progressBar.SetFraction(0.3)
+	progressBar.SetText("30%")
+	progressBar.SetShowText(true)
+	grid.Attach(progressBar, 0, 2, 1, 1)
+
+	separator, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+	grid.Attach(separator, 0, 3, 1, 1)
+
+	// offsets name the low, high and full style classes of the level bar
+	for i, value := range []float64{0.2, 0.6, 1} {
+		levelBar, _ := gtk.LevelBarNewForInterval(0, 1)
+		levelBar.SetValue(value)
+		grid.Attach(levelBar, 0, 4+i, 1, 1)
+	}
+
+	return grid
+}
+
+// setUpGalleryLists returns a sidebar switching pages, the first of them a tree view with a row selected
+func setUpGalleryLists() *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.SetProperty("margin", 6)
+
+	stack, _ := gtk.StackNew()
+	sidebar, _ := gtk.StackSidebarNew()
+	sidebar.SetStack(stack)
+	box.PackStart(sidebar, false, false, 0)
+	box.PackStart(stack, true, true, 0)
+
+	store, _ := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	rows := [][]interface{}{
+		{"Adwaita", "GTK2, GTK3, GTK4"},
+		{"Adwaita-dark", "GTK2, GTK3, GTK4"},
+		{"HighContrast", "GTK2, GTK3"},
+	}
+	var selected *gtk.TreeIter
+	for i, r := range rows {
+		iter := store.Append()
+		store.Set(iter, []int{0, 1}, r)
+		if i == 1 {
+			selected = iter
+		}
+	}
+
+	treeView, _ := gtk.TreeViewNewWithModel(store)
+	treeView.SetProperty("can-focus", false)
+	treeView.SetProperty("hexpand", true)
+	for i, title := range []string{voc["name"], voc["toolkit-support"]} {
+		renderer, _ := gtk.CellRendererTextNew()
+		column, _ := gtk.TreeViewColumnNewWithAttribute(title, renderer, "text", i)
+		treeView.AppendColumn(column)
+	}
+	selection, _ := treeView.GetSelection()
+	selection.SelectIter(selected)
+
+	frame, _ := gtk.FrameNew("")
+	frame.Add(treeView)
+	stack.AddTitled(frame, "gtk-theme", voc["gtk-theme"])
+
+	for _, key := range []string{"icon-theme", "widgets"} {
+		label, _ := gtk.LabelNew(voc[key])
+		stack.AddTitled(label, key, voc[key])
+	}
+
+	return box
+}
+
+// setUpGalleryPopups returns buttons opening a popover and a menu, a tooltip and info bars
+func setUpGalleryPopups() *gtk.Grid {
+	grid := newGalleryGrid()
+
+	popoverButton, _ := gtk.MenuButtonNew()
+	popoverButton.SetLabel(voc["popover"])
+	popover, _ := gtk.PopoverNew(popoverButton)
+	popoverBox, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	popoverBox.SetProperty("margin", 6)
+	for i := 1; i <= 3; i++ {
+		item, _ := gtk.ModelButtonNew()
+		item.SetLabel(fmt.Sprintf("%s %v", voc["entry"], i))
+		popoverBox.PackStart(item, false, false, 0)
+	}
+	popoverBox.ShowAll()
+	popover.Add(popoverBox)
+	popoverButton.SetPopover(popover)
+	grid.Attach(popoverButton, 0, 0, 1, 1)
+
+	menuButton, _ := gtk.MenuButtonNew()
+	menuButton.SetLabel(voc["menu"])
+	menu, _ := gtk.MenuNew()
+	for i := 1; i <= 3; i++ {
+		item, _ := gtk.MenuItemNewWithLabel(fmt.Sprintf("%s %v", voc["entry"], i))
+		item.SetSensitive(i != 3)
+		menu.Append(item)
+	}
+	check, _ := gtk.CheckMenuItemNewWithLabel(voc["check-button"])
+	check.SetActive(true)
+	menu.Append(check)
+	menu.ShowAll()
+	menuButton.SetPopup(menu)
+	grid.Attach(menuButton, 1, 0, 1, 1)
+
+	tooltipButton, _ := gtk.ButtonNewWithLabel(voc["tooltip"])
+	tooltipButton.SetTooltipText(voc["tooltip-hint"])
+	grid.Attach(tooltipButton, 2, 0, 1, 1)
+
+	infoBars := []struct {
+		messageType gtk.MessageType
+		text        string
+	}{
+		{gtk.MESSAGE_INFO, voc["info"]},
+		{gtk.MESSAGE_WARNING, voc["warning"]},
+		{gtk.MESSAGE_ERROR, voc["error"]},
+	}
+	for i, b := range infoBars {
+		infoBar, _ := gtk.InfoBarNew()
+		infoBar.SetMessageType(b.messageType)
+		infoBar.SetProperty("hexpand", true)
+		content, _ := infoBar.GetContentArea()
+		label, _ := gtk.LabelNew(b.text)
+		content.PackStart(label, false, false, 0)
+		if b.messageType == gtk.MESSAGE_ERROR {
+			infoBar.AddButton(voc["close"], gtk.RESPONSE_CLOSE)
+		}
+		grid.Attach(infoBar, 0, 1+i, 3, 1)
+	}
+
+	return grid
+}
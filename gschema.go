@@ -0,0 +1,364 @@
+// GSettings schema parsing for the Advanced page
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// schemas editable on the Advanced page, and included in the gsettings backup
+var advancedSchemas = []string{
+	"org.gnome.desktop.interface",
+	"org.gnome.desktop.a11y.interface",
+	"org.gnome.desktop.sound",
+	"org.gnome.desktop.wm.preferences",
+}
+
+// pending Advanced page changes: map[schema]map[key]GVariant text
+var gsettingsAdvanced = make(map[string]map[string]string)
+
+// values of gsettingsFields keys as last read or set, by key; applyGsettings only sets keys that differ
+var gsettingsLoaded = make(map[string]string)
+
+// gsettingsField binds a gsettings key to the gsettingsValues field that holds it
+type gsettingsField struct {
+	schema string
+	key    string
+	value  func(g *gsettingsValues) interface{} // pointer to the gsettingsValues field
+}
+
+// keys read with readGsettings, set with applyGsettings and saved in the plain part of the backup, in that order
+var gsettingsFields = []gsettingsField{
+	{"org.gnome.desktop.interface", "gtk-theme", func(g *gsettingsValues) interface{} { return &g.gtkTheme }},
+	{"org.gnome.desktop.interface", "icon-theme", func(g *gsettingsValues) interface{} { return &g.iconTheme }},
+	{"org.gnome.desktop.interface", "font-name", func(g *gsettingsValues) interface{} { return &g.fontName }},
+	{"org.gnome.desktop.interface", "monospace-font-name", func(g *gsettingsValues) interface{} { return &g.monospaceFontName }},
+	{"org.gnome.desktop.interface", "document-font-name", func(g *gsettingsValues) interface{} { return &g.documentFontName }},
+	{"org.gnome.desktop.interface", "gtk-key-theme", func(g *gsettingsValues) interface{} { return &g.keyTheme }},
+	{"org.gnome.desktop.interface", "cursor-theme", func(g *gsettingsValues) interface{} { return &g.cursorTheme }},
+	{"org.gnome.desktop.interface", "cursor-size", func(g *gsettingsValues) interface{} { return &g.cursorSize }},
+	{"org.gnome.desktop.interface", "toolbar-style", func(g *gsettingsValues) interface{} { return &g.toolbarStyle }},
+	{"org.gnome.desktop.interface", "toolbar-icons-size", func(g *gsettingsValues) interface{} { return &g.toolbarIconsSize }},
+	{"org.gnome.desktop.interface", "font-hinting", func(g *gsettingsValues) interface{} { return &g.fontHinting }},
+	{"org.gnome.desktop.interface", "font-antialiasing", func(g *gsettingsValues) interface{} { return &g.fontAntialiasing }},
+	{"org.gnome.desktop.interface", "font-rgba-order", func(g *gsettingsValues) interface{} { return &g.fontRgbaOrder }},
+	{"org.gnome.desktop.interface", "text-scaling-factor", func(g *gsettingsValues) interface{} { return &g.textScalingFactor }},
+	{"org.gnome.desktop.interface", "color-scheme", func(g *gsettingsValues) interface{} { return &g.colorScheme }},
+	{"org.gnome.desktop.interface", "enable-animations", func(g *gsettingsValues) interface{} { return &g.enableAnimations }},
+	{"org.gnome.desktop.interface", "cursor-blink", func(g *gsettingsValues) interface{} { return &g.cursorBlink }},
+	{"org.gnome.desktop.interface", "cursor-blink-time", func(g *gsettingsValues) interface{} { return &g.cursorBlinkTime }},
+	{"org.gnome.desktop.interface", "cursor-blink-timeout", func(g *gsettingsValues) interface{} { return &g.cursorBlinkTimeout }},
+	{"org.gnome.desktop.interface", "overlay-scrolling", func(g *gsettingsValues) interface{} { return &g.overlayScrolling }},
+	{"org.gnome.desktop.a11y.interface", "high-contrast", func(g *gsettingsValues) interface{} { return &g.highContrast }},
+	{"org.gnome.desktop.wm.preferences", "titlebar-font", func(g *gsettingsValues) interface{} { return &g.titlebarFont }},
+	{"org.gnome.desktop.wm.preferences", "button-layout", func(g *gsettingsValues) interface{} { return &g.buttonLayout }},
+	{"org.gnome.desktop.wm.preferences", "action-double-click-titlebar", func(g *gsettingsValues) interface{} { return &g.actionDoubleClickTitlebar }},
+	{"org.gnome.desktop.wm.preferences", "action-middle-click-titlebar", func(g *gsettingsValues) interface{} { return &g.actionMiddleClickTitlebar }},
+	{"org.gnome.desktop.wm.preferences", "action-right-click-titlebar", func(g *gsettingsValues) interface{} { return &g.actionRightClickTitlebar }},
+	{"org.gnome.desktop.sound", "event-sounds", func(g *gsettingsValues) interface{} { return &g.eventSounds }},
+	{"org.gnome.desktop.sound", "input-feedback-sounds", func(g *gsettingsValues) interface{} { return &g.inputFeedbackSounds }},
+	{"org.gnome.desktop.sound", "theme-name", func(g *gsettingsValues) interface{} { return &g.soundTheme }},
+}
+
+// String returns the value as passed to `gsettings set` and saved in the backup
+func (f gsettingsField) String(g *gsettingsValues) string {
+	switch v := f.value(g).(type) {
+	case *string:
+		return *v
+	case *int:
+		return strconv.Itoa(*v)
+	case *float64:
+		return fmt.Sprintf("%f", *v)
+	case *bool:
+		return strconv.FormatBool(*v)
+	}
+	return ""
+}
+
+// parse sets the field of g from the output of getGsettingsValue, or a value of the backup
+func (f gsettingsField) parse(g *gsettingsValues, value string) error {
+	switch v := f.value(g).(type) {
+	case *string:
+		*v = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*v = n
+	case *float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*v = n
+	case *bool:
+		*v = value == "true"
+	}
+	return nil
+}
+
+// gsettingsFieldByKey returns the field of the key in the plain part of the backup, where keys are unique
+func gsettingsFieldByKey(key string) (gsettingsField, bool) {
+	for _, f := range gsettingsFields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return gsettingsField{}, false
+}
+
+func isGsettingsField(schema, key string) bool {
+	for _, f := range gsettingsFields {
+		if f.schema == schema && f.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+type gschemaList struct {
+	Enums   []gschemaEnum `xml:"enum"`
+	Flags   []gschemaEnum `xml:"flags"`
+	Schemas []gschema     `xml:"schema"`
+}
+
+type gschemaEnum struct {
+	ID     string `xml:"id,attr"`
+	Values []struct {
+		Nick string `xml:"nick,attr"`
+	} `xml:"value"`
+}
+
+type gschema struct {
+	ID   string       `xml:"id,attr"`
+	Keys []gschemaKey `xml:"key"`
+}
+
+type gschemaKey struct {
+	Name        string `xml:"name,attr"`
+	Type        string `xml:"type,attr"`
+	Enum        string `xml:"enum,attr"`
+	Flags       string `xml:"flags,attr"`
+	Default     string `xml:"default"`
+	Summary     string `xml:"summary"`
+	Description string `xml:"description"`
+	Choices     []struct {
+		Value string `xml:"value,attr"`
+	} `xml:"choices>choice"`
+	Range struct {
+		Min string `xml:"min,attr"`
+		Max string `xml:"max,attr"`
+	} `xml:"range"`
+
+	// allowed values, resolved from Enum or Choices
+	nicks []string
+}
+
+// loadGSchemas parses schema sources from glib-2.0/schemas in data dirs, and returns the requested schemas
+func loadGSchemas(ids []string) map[string]gschema {
+	schemas := make(map[string]gschema)
+	enums := make(map[string][]string)
+
+	for _, d := range dataDirs {
+		files, err := filepath.Glob(filepath.Join(d, "glib-2.0/schemas/*.xml"))
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				log.Warnf("Couldn't read %s: %s", f, err)
+				continue
+			}
+			var list gschemaList
+			if err := xml.Unmarshal(data, &list); err != nil {
+				log.Debugf("Couldn't parse %s: %s", f, err)
+				continue
+			}
+			for _, e := range list.Enums {
+				if _, ok := enums[e.ID]; !ok {
+					for _, v := range e.Values {
+						enums[e.ID] = append(enums[e.ID], v.Nick)
+					}
+				}
+			}
+			for _, s := range list.Schemas {
+				if _, ok := schemas[s.ID]; !ok && isIn(ids, s.ID) {
+					schemas[s.ID] = s
+					log.Debugf("Schema %s found in %s", s.ID, f)
+				}
+			}
+		}
+	}
+
+	for id, s := range schemas {
+		for i := range s.Keys {
+			k := &s.Keys[i]
+			k.Summary = strings.Join(strings.Fields(k.Summary), " ")
+			k.Description = strings.Join(strings.Fields(k.Description), " ")
+			k.Default = strings.TrimSpace(k.Default)
+			if k.Enum != "" {
+				k.nicks = enums[k.Enum]
+			}
+			for _, c := range k.Choices {
+				k.nicks = append(k.nicks, c.Value)
+			}
+		}
+		schemas[id] = s
+	}
+
+	return schemas
+}
+
+// getGsettingsSchemaValues returns map[key]GVariant text for all keys of the schema
+func getGsettingsSchemaValues(schema string) (map[string]string, error) {
+	out, err := exec.Command("gsettings", "list-recursively", schema).Output()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		// schema key value
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) == 3 && parts[0] == schema {
+			values[parts[1]] = parts[2]
+		}
+	}
+	return values, nil
+}
+
+// gschemaDefaults returns map[schema]map[key]default GVariant text, for schemas found by loadGSchemas
+func gschemaDefaults(ids []string) map[string]map[string]string {
+	defaults := make(map[string]map[string]string)
+	for id, s := range loadGSchemas(ids) {
+		defaults[id] = make(map[string]string)
+		for _, k := range s.Keys {
+			defaults[id][k.Name] = k.Default
+		}
+	}
+	return defaults
+}
+
+// isGschemaDefault checks if the GVariant text is the default of the key. Type annotations and quoting
+// of strings are ignored, as gsettings and schema sources write them differently.
+func isGschemaDefault(defaults map[string]string, key, value string) bool {
+	d, ok := defaults[key]
+	return ok && gvariantUnquote(d) == gvariantUnquote(value)
+}
+
+// gvariantUnquote converts GVariant text of a string or a number into a plain value
+func gvariantUnquote(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0] {
+		s := text[1 : len(text)-1]
+		s = strings.ReplaceAll(s, fmt.Sprintf("\\%c", text[0]), string(text[0]))
+		return strings.ReplaceAll(s, "\\\\", "\\")
+	}
+	// type annotations, e.g. "uint32 10"
+	fields := strings.Fields(text)
+	if len(fields) == 2 {
+		return fields[1]
+	}
+	return text
+}
+
+func gvariantQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(s, "'", "\\'"))
+}
+
+// gschemaKeyRange returns spin button limits for numeric key types
+func gschemaKeyRange(k gschemaKey) (float64, float64, bool) {
+	var min, max float64
+	switch k.Type {
+	case "i":
+		min, max = -2147483648, 2147483647
+	case "u":
+		min, max = 0, 4294967295
+	case "n":
+		min, max = -32768, 32767
+	case "q":
+		min, max = 0, 65535
+	case "y":
+		min, max = 0, 255
+	case "x", "t", "d":
+		min, max = -1e15, 1e15
+		if k.Type == "t" {
+			min = 0
+		}
+	default:
+		return 0, 0, false
+	}
+	if v, err := strconv.ParseFloat(k.Range.Min, 64); err == nil {
+		min = v
+	}
+	if v, err := strconv.ParseFloat(k.Range.Max, 64); err == nil {
+		max = v
+	}
+	return min, max, true
+}
+
+func setAdvancedGsetting(schema, key, value string) {
+	if gsettingsAdvanced[schema] == nil {
+		gsettingsAdvanced[schema] = make(map[string]string)
+	}
+	gsettingsAdvanced[schema][key] = value
+}
+
+// resetUnlistedAdvancedGsettings queues resets of keys that backup sections leave out, as the backup only
+// lists keys changed from their defaults. Schemas with no section, as in backups of older versions, are skipped.
+func resetUnlistedAdvancedGsettings(listed map[string]map[string]bool) {
+	defaults := gschemaDefaults(advancedSchemas)
+	for schema, keys := range listed {
+		if defaults[schema] == nil {
+			log.Warnf("Schema source of %s not found, keys missing from the backup are left alone", schema)
+			continue
+		}
+		values, err := getGsettingsSchemaValues(schema)
+		if err != nil {
+			log.Warnf("Couldn't list gsettings schema: %s", schema)
+			continue
+		}
+		for key, value := range values {
+			d, ok := defaults[schema][key]
+			if !ok || keys[key] || isGsettingsField(schema, key) || isGschemaDefault(defaults[schema], key, value) {
+				continue
+			}
+			setAdvancedGsetting(schema, key, d)
+		}
+	}
+}
+
+// applyAdvancedGsettings sets keys changed on the Advanced page, and re-reads values used by other pages
+func applyAdvancedGsettings() {
+	if len(gsettingsAdvanced) == 0 {
+		return
+	}
+	log.Info(">>> Applying advanced gsettings")
+	defaults := gschemaDefaults(advancedSchemas)
+	for schema, keys := range gsettingsAdvanced {
+		log.Infof(">> %s", schema)
+		for key, val := range keys {
+			// default values are reset rather than stored in dconf
+			cmd := exec.Command("gsettings", "set", schema, key, val)
+			if isGschemaDefault(defaults[schema], key, val) {
+				cmd = exec.Command("gsettings", "reset", schema, key)
+			}
+			if out, err := cmd.CombinedOutput(); err != nil {
+				log.Warnf("%s: %s %s %s", key, val, err, strings.TrimSpace(string(out)))
+			} else {
+				log.Infof("%s: %s OK", key, val)
+			}
+		}
+	}
+	gsettingsAdvanced = make(map[string]map[string]string)
+	readGsettings()
+}
@@ -0,0 +1,434 @@
+// settings import from existing settings.ini / gtkrc-2.0 files, e.g. created by LXAppearance
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+// gtkSettingKeys maps GTK settings to gsettingsFields keys they convert to without translation
+var gtkSettingKeys = map[string]string{
+	"gtk-theme-name":                   "gtk-theme",
+	"gtk-icon-theme-name":              "icon-theme",
+	"gtk-font-name":                    "font-name",
+	"gtk-key-theme-name":               "gtk-key-theme",
+	"gtk-cursor-theme-name":            "cursor-theme",
+	"gtk-cursor-theme-size":            "cursor-size",
+	"gtk-enable-animations":            "enable-animations",
+	"gtk-cursor-blink":                 "cursor-blink",
+	"gtk-cursor-blink-time":            "cursor-blink-time",
+	"gtk-cursor-blink-timeout":         "cursor-blink-timeout",
+	"gtk-overlay-scrolling":            "overlay-scrolling",
+	"gtk-decoration-layout":            "button-layout",
+	"gtk-enable-event-sounds":          "event-sounds",
+	"gtk-enable-input-feedback-sounds": "input-feedback-sounds",
+	"gtk-sound-theme-name":             "theme-name",
+}
+
+// importValue converts a GTK setting value to the text gsettingsField.parse takes, and returns false
+// if the value isn't valid for the field: empty strings, sizes and times below 1, non-boolean flags
+func importValue(f gsettingsField, value string) (string, bool) {
+	switch f.value(&gsettingsValues{}).(type) {
+	case *bool:
+		switch strings.ToLower(value) {
+		case "1", "true":
+			return "true", true
+		case "0", "false":
+			return "false", true
+		}
+		return "", false
+	case *int:
+		i, err := strconv.Atoi(value)
+		return value, err == nil && i > 0
+	}
+	return value, value != ""
+}
+
+// parseGtkSettings returns gtk-* settings from settings.ini or gtkrc text. Values are unquoted,
+// gtkrc style blocks and settings.ini sections other than [Settings] are skipped.
+func parseGtkSettings(text string) map[string]string {
+	settings := make(map[string]string)
+	section, depth := "", 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		inBlock := depth > 0
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth < 0 {
+			depth = 0
+		}
+		if inBlock || strings.Contains(line, "{") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != "" && section != "Settings" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !strings.HasPrefix(key, "gtk-") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		settings[key] = value
+	}
+	return settings
+}
+
+func parseGtkSettingsFile(path string) (map[string]string, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseGtkSettings(string(bytes)), nil
+}
+
+// importGtkSettings converts GTK settings to gsettings values, starting from the base values.
+// It returns the result, and keys of gsettingsFields the settings provided values for.
+func importGtkSettings(settings map[string]string, base gsettingsValues) (gsettingsValues, []string) {
+	g := base
+	found := make(map[string]bool)
+
+	set := func(key, value string) {
+		f, ok := gsettingsFieldByKey(key)
+		if !ok {
+			return
+		}
+		if value, ok = importValue(f, value); !ok {
+			return
+		}
+		if err := f.parse(&g, value); err != nil {
+			log.Warnf("%s: %s", key, err)
+			return
+		}
+		found[key] = true
+	}
+
+	for name, value := range settings {
+		if key, ok := gtkSettingKeys[name]; ok {
+			set(key, value)
+		}
+	}
+
+	// GTK_TOOLBAR_BOTH_HORIZ -> both-horiz
+	if style := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(settings["gtk-toolbar-style"], "GTK_TOOLBAR_")), "_", "-"); isIn([]string{"both", "both-horiz", "icons", "text"}, style) {
+		set("toolbar-style", style)
+	}
+	switch settings["gtk-toolbar-icon-size"] {
+	case "GTK_ICON_SIZE_SMALL_TOOLBAR":
+		set("toolbar-icons-size", "small")
+	case "GTK_ICON_SIZE_LARGE_TOOLBAR":
+		set("toolbar-icons-size", "large")
+	}
+
+	// the dark preference only tells prefer-dark from the rest
+	switch strings.ToLower(settings["gtk-application-prefer-dark-theme"]) {
+	case "1", "true":
+		set("color-scheme", "prefer-dark")
+	case "0", "false":
+		if g.colorScheme == "prefer-dark" {
+			set("color-scheme", "default")
+		} else {
+			found["color-scheme"] = true
+		}
+	}
+
+	// font rendering is spread across several gtk-xft-* settings
+	if settings["gtk-xft-hinting"] == "0" {
+		set("font-hinting", "none")
+	} else if style := strings.TrimPrefix(settings["gtk-xft-hintstyle"], "hint"); isIn([]string{"none", "slight", "medium", "full"}, style) {
+		set("font-hinting", style)
+	}
+
+	rgba := settings["gtk-xft-rgba"]
+	switch {
+	case settings["gtk-xft-antialias"] == "0":
+		set("font-antialiasing", "none")
+	case isIn([]string{"rgb", "bgr", "vrgb", "vbgr"}, rgba):
+		set("font-antialiasing", "rgba")
+		set("font-rgba-order", rgba)
+	case rgba == "none" || settings["gtk-xft-antialias"] == "1":
+		set("font-antialiasing", "grayscale")
+	}
+
+	var keys []string
+	for _, f := range gsettingsFields {
+		if found[f.key] {
+			keys = append(keys, f.key)
+		}
+	}
+	return g, keys
+}
+
+// importDifference is an imported value that differs from the current one
+type importDifference struct {
+	key      string
+	current  string
+	imported string
+}
+
+func importDifferences(current, imported gsettingsValues, keys []string) []importDifference {
+	var diffs []importDifference
+	for _, f := range gsettingsFields {
+		if !isIn(keys, f.key) {
+			continue
+		}
+		c, i := f.String(&current), f.String(&imported)
+		if c != i {
+			diffs = append(diffs, importDifference{key: f.key, current: c, imported: i})
+		}
+	}
+	return diffs
+}
+
+// mergeImported copies values of the given keys from imported to g
+func mergeImported(g *gsettingsValues, imported gsettingsValues, keys []string) {
+	for _, f := range gsettingsFields {
+		if isIn(keys, f.key) {
+			if err := f.parse(g, f.String(&imported)); err != nil {
+				log.Warnf("%s: %s", f.key, err)
+			}
+		}
+	}
+}
+
+// importCandidates returns existing GTK settings files with values other than current gsettings
+func importCandidates() []string {
+	var files []string
+	for _, f := range []string{filepath.Join(configHome(), "gtk-3.0/settings.ini"), gtkRc20File()} {
+		if f == "" || !pathExists(f) || isIn(files, f) {
+			continue
+		}
+		settings, err := parseGtkSettingsFile(f)
+		if err != nil {
+			log.Warnf("Couldn't read %s: %s", f, err)
+			continue
+		}
+		if imported, keys := importGtkSettings(settings, gsettings); len(importDifferences(gsettings, imported, keys)) > 0 {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// applyImport sets chosen imported values, applies gsettings and exports config files
+func applyImport(imported gsettingsValues, keys []string) {
+	log.Infof(">>> Importing: %s", strings.Join(keys, ", "))
+	mergeImported(&gsettings, imported, keys)
+
+	applyGsettings()
+	saveGsettingsBackup()
+
+	if preferences.ExportSettingsIni {
+		saveGtkIni3()
+	}
+	if preferences.ExportGtkRc20 {
+		saveGtkRc20()
+	}
+	if preferences.ExportIndexTheme {
+		saveIndexTheme()
+	}
+	if preferences.ExportXsettingsd {
+		saveXsettingsd()
+	}
+	if preferences.ExportGtk4Symlinks {
+		if gtkThemePaths == nil {
+			_, gtkThemePaths = getThemeNames()
+		}
+		linkGtk4Stuff()
+		saveGtkIni4()
+	}
+	if preferences.ExportFontconfig {
+		saveFontconfig()
+	}
+	if preferences.ExportXresources {
+		saveXresources()
+	}
+}
+
+// importSettingsFile is the `nwg-look import FILE` command
+func importSettingsFile(path string) int {
+	settings, err := parseGtkSettingsFile(path)
+	if err != nil {
+		fmt.Printf("Couldn't read %s: %s\n", path, err)
+		return 1
+	}
+	imported, keys := importGtkSettings(settings, gsettings)
+	diffs := importDifferences(gsettings, imported, keys)
+	if len(diffs) == 0 {
+		fmt.Printf("Nothing to import, settings in %s match current gsettings\n", path)
+		return 0
+	}
+
+	keyWidth, currentWidth := len("Setting"), len("Current")
+	for _, d := range diffs {
+		keyWidth = max(keyWidth, len(d.key))
+		currentWidth = max(currentWidth, len(d.current))
+	}
+	fmt.Printf("%-*s  %-*s  %s\n", keyWidth, "Setting", currentWidth, "Current", "Imported")
+	for _, d := range diffs {
+		fmt.Printf("%-*s  %-*s  %s\n", keyWidth, d.key, currentWidth, d.current, d.imported)
+	}
+	fmt.Println()
+
+	var chosen []string
+	for _, d := range diffs {
+		fmt.Printf("%s: use imported '%s'? Y/n ", d.key, d.imported)
+		var input string
+		fmt.Scanln(&input)
+		if strings.ToUpper(input) != "N" {
+			chosen = append(chosen, d.key)
+		}
+	}
+	if len(chosen) == 0 {
+		return 0
+	}
+
+	fmt.Printf("Apply %v imported value(s)? y/N ", len(chosen))
+	var input string
+	fmt.Scanln(&input)
+	if strings.ToUpper(input) != "Y" {
+		return 0
+	}
+
+	// keep values that only live in settings.ini, like gtk-xft-dpi
+	loadGtkConfig()
+	applyImport(imported, chosen)
+	return 0
+}
+
+// showImportWizard offers to import existing GTK settings files, field by field. Returns true if
+// anything was imported.
+func showImportWizard(files []string) bool {
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle(voc["import-settings"])
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(640, 480)
+	dialog.AddButton(voc["skip"], gtk.RESPONSE_CANCEL)
+	dialog.AddButton(voc["apply"], gtk.RESPONSE_OK)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	content, _ := dialog.GetContentArea()
+	content.SetSpacing(6)
+	content.SetProperty("margin", 12)
+
+	lbl, _ := gtk.LabelNew(voc["import-settings-intro"])
+	lbl.SetLineWrap(true)
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	content.PackStart(lbl, false, false, 0)
+
+	hBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	content.PackStart(hBox, false, false, 0)
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["import-from"]))
+	hBox.PackStart(lbl, false, false, 0)
+	combo, _ := gtk.ComboBoxTextNew()
+	for _, f := range files {
+		combo.AppendText(f)
+	}
+	hBox.PackStart(combo, true, true, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	content.PackStart(scrolled, true, true, 0)
+	// an explicit viewport, as the grid is replaced when another file is chosen
+	vp, _ := gtk.ViewportNew(nil, nil)
+	scrolled.Add(vp)
+
+	var imported gsettingsValues
+	var chosen []string
+	var g *gtk.Grid
+
+	compare := func(path string) {
+		if g != nil {
+			g.Destroy()
+		}
+		g, _ = gtk.GridNew()
+		g.SetRowSpacing(6)
+		g.SetColumnSpacing(12)
+		g.SetProperty("margin", 6)
+		vp.Add(g)
+
+		var keys []string
+		settings, err := parseGtkSettingsFile(path)
+		if err != nil {
+			log.Warnf("Couldn't read %s: %s", path, err)
+		}
+		imported, keys = importGtkSettings(settings, gsettings)
+		diffs := importDifferences(gsettings, imported, keys)
+
+		chosen = nil
+		for i, header := range []string{voc["setting"], voc["current"], voc["imported"]} {
+			lbl, _ := gtk.LabelNew("")
+			lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", header))
+			lbl.SetProperty("halign", gtk.ALIGN_START)
+			g.Attach(lbl, i, 0, 1, 1)
+		}
+		if len(diffs) == 0 {
+			lbl, _ := gtk.LabelNew(voc["import-nothing"])
+			lbl.SetProperty("halign", gtk.ALIGN_START)
+			g.Attach(lbl, 0, 1, 3, 1)
+		}
+		for row, d := range diffs {
+			key := d.key
+			lbl, _ := gtk.LabelNew(key)
+			lbl.SetProperty("halign", gtk.ALIGN_START)
+			g.Attach(lbl, 0, row+1, 1, 1)
+
+			rbCurrent, _ := gtk.RadioButtonNewWithLabel(nil, d.current)
+			g.Attach(rbCurrent, 1, row+1, 1, 1)
+			rbImported, _ := gtk.RadioButtonNewWithLabelFromWidget(rbCurrent, d.imported)
+			g.Attach(rbImported, 2, row+1, 1, 1)
+
+			// the imported value wins unless the user says otherwise
+			rbImported.SetActive(true)
+			chosen = append(chosen, key)
+			rbImported.Connect("toggled", func() {
+				var keys []string
+				for _, k := range chosen {
+					if k != key {
+						keys = append(keys, k)
+					}
+				}
+				if rbImported.GetActive() {
+					keys = append(keys, key)
+				}
+				chosen = keys
+			})
+		}
+		g.ShowAll()
+	}
+
+	combo.Connect("changed", func() {
+		compare(combo.GetActiveText())
+	})
+	combo.SetActive(0)
+
+	dialog.ShowAll()
+	response := dialog.Run()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_OK || len(chosen) == 0 {
+		return false
+	}
+	applyImport(imported, chosen)
+	return true
+}
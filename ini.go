@@ -0,0 +1,210 @@
+// settings.ini read/modify/write, shared by GTK 3 and GTK 4 exporters
+package main
+
+import (
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// iniLine is a single line of an INI document. Lines not modified by Set are written back unchanged.
+type iniLine struct {
+	raw     string
+	section string // section the line belongs to, or the name of the section it starts
+	header  bool
+	key     string // empty for section headers, comments, blank and malformed lines
+	value   string
+}
+
+// iniDocument keeps all lines of an INI file, including comments, whitespace and line order
+type iniDocument struct {
+	lines []iniLine
+}
+
+func parseIniLine(raw, section string) iniLine {
+	l := iniLine{raw: raw, section: section}
+	trimmed := strings.TrimSpace(raw)
+	switch {
+	case trimmed == "", strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, ";"):
+	case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+		l.header = true
+		l.section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+	default:
+		// values may contain '=', only the first one separates the key
+		key, value, ok := strings.Cut(trimmed, "=")
+		if ok {
+			l.key = strings.TrimSpace(key)
+			l.value = strings.TrimSpace(value)
+		}
+	}
+	return l
+}
+
+// parseIni parses INI text; String() of the result returns the same text
+func parseIni(text string) *iniDocument {
+	d := &iniDocument{}
+	section := ""
+	for _, raw := range strings.Split(text, "\n") {
+		l := parseIniLine(raw, section)
+		section = l.section
+		d.lines = append(d.lines, l)
+	}
+	return d
+}
+
+func (d *iniDocument) String() string {
+	raws := make([]string, len(d.lines))
+	for i, l := range d.lines {
+		raws[i] = l.raw
+	}
+	return strings.Join(raws, "\n")
+}
+
+// Get returns the value of the first occurrence of the key in the section
+func (d *iniDocument) Get(section, key string) (string, bool) {
+	for _, l := range d.lines {
+		if l.section == section && l.key == key {
+			return l.value, true
+		}
+	}
+	return "", false
+}
+
+// Keys returns keys of the section in document order
+func (d *iniDocument) Keys(section string) []string {
+	var keys []string
+	for _, l := range d.lines {
+		if l.section == section && l.key != "" && !isIn(keys, l.key) {
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Set updates the key in place, keeping indentation and spacing around '='. Later duplicates of the key
+// in the section are removed. A missing key is added after the last key of the section, and a missing
+// section is added at the end of the document.
+func (d *iniDocument) Set(section, key, value string) {
+	found := false
+	for i := 0; i < len(d.lines); i++ {
+		l := &d.lines[i]
+		if l.section != section || l.key != key {
+			continue
+		}
+		if found {
+			d.lines = append(d.lines[:i], d.lines[i+1:]...)
+			i--
+			continue
+		}
+		found = true
+		if l.value != value {
+			eq := strings.Index(l.raw, "=")
+			prefix := l.raw[:eq+1]
+			rest := l.raw[eq+1:]
+			prefix += rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+			eol := ""
+			if strings.HasSuffix(l.raw, "\r") {
+				eol = "\r"
+			}
+			l.raw = prefix + value + eol
+			l.value = value
+		}
+	}
+	if found {
+		return
+	}
+
+	newLine := iniLine{raw: key + "=" + value, section: section, key: key, value: value}
+
+	insertAt, headerAt := -1, -1
+	for i, l := range d.lines {
+		if l.section != section {
+			continue
+		}
+		if l.header && headerAt == -1 {
+			headerAt = i
+		}
+		if l.key != "" || (l.header && insertAt == -1) {
+			insertAt = i + 1
+		}
+	}
+	if headerAt == -1 && section != "" {
+		// drop the empty line left by a trailing newline, to re-add it after the new section
+		trailingNewline := len(d.lines) > 0 && d.lines[len(d.lines)-1].raw == ""
+		if trailingNewline {
+			d.lines = d.lines[:len(d.lines)-1]
+		}
+		if len(d.lines) > 0 && strings.TrimSpace(d.lines[len(d.lines)-1].raw) != "" {
+			d.lines = append(d.lines, iniLine{section: d.lines[len(d.lines)-1].section})
+		}
+		d.lines = append(d.lines, iniLine{raw: "[" + section + "]", section: section, header: true}, newLine)
+		d.lines = append(d.lines, iniLine{section: section})
+		return
+	}
+	if insertAt == -1 {
+		insertAt = 0
+	}
+	d.lines = append(d.lines[:insertAt], append([]iniLine{newLine}, d.lines[insertAt:]...)...)
+}
+
+// Delete removes all occurrences of the key from the section
+func (d *iniDocument) Delete(section, key string) {
+	var lines []iniLine
+	for _, l := range d.lines {
+		if l.section == section && l.key == key {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	d.lines = lines
+}
+
+// loadIni returns the parsed INI file, or an empty document if the file doesn't exist
+func loadIni(path string) (*iniDocument, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return parseIni(""), nil
+		}
+		return nil, err
+	}
+	return parseIni(string(bytes)), nil
+}
+
+// mergeSettingsIni sets managed `key=value` lines in the [Settings] section of the document, and removes
+// other keys the isManaged function claims, as they're no longer set by nwg-look. Everything else stays as is.
+func mergeSettingsIni(d *iniDocument, managed []string, isManaged func(string) bool) {
+	var managedKeys []string
+	for _, m := range managed {
+		key, value, _ := strings.Cut(m, "=")
+		d.Set("Settings", key, value)
+		managedKeys = append(managedKeys, key)
+	}
+	for _, key := range d.Keys("Settings") {
+		if isManaged(key) && !isIn(managedKeys, key) {
+			d.Delete("Settings", key)
+		}
+	}
+}
+
+// saveSettingsIni writes managed lines to the settings.ini file, preserving lines nwg-look doesn't manage
+func saveSettingsIni(configFile string, managed []string, isManaged func(string) bool) {
+	d, err := loadIni(configFile)
+	if err != nil {
+		log.Warnf("Couldn't load %s: %s", configFile, err)
+		return
+	}
+
+	mergeSettingsIni(d, managed, isManaged)
+
+	text := d.String()
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	log.Debug(text)
+
+	if err := os.WriteFile(configFile, []byte(text), 0644); err != nil {
+		log.Warnf("Couldn't write %s: %s", configFile, err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func loadIniSample(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "ini", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestIniRoundTripSamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "ini", "*.ini"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no samples: %v", err)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parseIni(string(data)).String(); got != string(data) {
+			t.Errorf("%s: round trip changed the text:\n%q\nwant\n%q", f, got, data)
+		}
+	}
+}
+
+func TestIniRoundTripProperty(t *testing.T) {
+	roundTrip := func(text string) bool {
+		return parseIni(text).String() == text
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+
+	// documents made of INI-like lines, more likely to hit sections and keys than random text
+	fragments := []string{
+		"", " ", "\t", "[Settings]", "[ Settings ]", "[Other]", "# comment", "; comment", "key=value",
+		"  key = value  ", "gtk-font-name=Sans=10", "=", "no equals", "[broken", "gtk-theme-name=Adwaita\r",
+	}
+	lines := func(picks []uint8) bool {
+		var raws []string
+		for _, p := range picks {
+			raws = append(raws, fragments[int(p)%len(fragments)])
+		}
+		text := strings.Join(raws, "\n")
+		return parseIni(text).String() == text
+	}
+	if err := quick.Check(lines, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIniGetAndKeys(t *testing.T) {
+	d := parseIni(loadIniSample(t, "duplicates.ini"))
+	want := []string{"gtk-theme-name", "gtk-icon-theme-name", "gtk-xft-rgba", "gtk-cursor-blink"}
+	if got := d.Keys("Settings"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys(Settings) = %q, want %q", got, want)
+	}
+	if v, _ := d.Get("Settings", "gtk-theme-name"); v != "Adwaita" {
+		t.Errorf("Get returned %q, want the first occurrence", v)
+	}
+	if v, _ := d.Get("Other", "gtk-theme-name"); v != "ignored" {
+		t.Errorf("Get(Other) = %q", v)
+	}
+
+	d = parseIni(loadIniSample(t, "outside-section.ini"))
+	if got := d.Keys(""); !reflect.DeepEqual(got, []string{"gtk-modules"}) {
+		t.Errorf("Keys outside sections = %q", got)
+	}
+	if v, _ := d.Get("Settings", "gtk-font-name"); v != "Noto Sans,  10" {
+		t.Errorf("Get(gtk-font-name) = %q", v)
+	}
+
+	d = parseIni(loadIniSample(t, "crlf.ini"))
+	if v, _ := d.Get("Settings", "gtk-font-name"); v != "Noto Sans=10" {
+		t.Errorf("value with '=' = %q", v)
+	}
+	if _, ok := d.Get("Settings", "missing"); ok {
+		t.Error("Get found a missing key")
+	}
+}
+
+func TestIniSet(t *testing.T) {
+	tests := []struct {
+		sample, section, key, value string
+		old, new                    string // replaced text of the sample
+	}{
+		{
+			// spacing around '=' is kept
+			"commented.ini", "Settings", "gtk-theme-name", "Nordic",
+			"  gtk-theme-name = Arc-Dark   \n", "  gtk-theme-name = Nordic\n",
+		},
+		{
+			// later duplicates are removed, other sections are left alone
+			"duplicates.ini", "Settings", "gtk-theme-name", "Nordic",
+			"gtk-theme-name=Adwaita\ngtk-theme-name=Adwaita-dark\n", "gtk-theme-name=Nordic\n",
+		},
+		{
+			// new keys go after the last key of the section, here in its second occurrence
+			"duplicates.ini", "Settings", "gtk-font-name", "Sans 10",
+			"gtk-cursor-blink=false\n", "gtk-cursor-blink=false\ngtk-font-name=Sans 10\n",
+		},
+		{
+			"lxappearance.ini", "Other", "key", "value",
+			"gtk-xft-hintstyle=hintfull\n", "gtk-xft-hintstyle=hintfull\n\n[Other]\nkey=value\n",
+		},
+		{
+			"outside-section.ini", "", "gtk-modules", "canberra-gtk-module",
+			"gtk-modules=appmenu-gtk-module\n", "gtk-modules=canberra-gtk-module\n",
+		},
+		{
+			"outside-section.ini", "", "gtk-im-module", "ibus",
+			"gtk-modules=appmenu-gtk-module\n", "gtk-modules=appmenu-gtk-module\ngtk-im-module=ibus\n",
+		},
+		{
+			// line endings are kept
+			"crlf.ini", "Settings", "gtk-theme-name", "Breeze-Dark",
+			"gtk-theme-name=Breeze\r\n", "gtk-theme-name=Breeze-Dark\r\n",
+		},
+		{
+			"no-trailing-newline.ini", "Settings", "gtk-cursor-theme-size", "32",
+			"gtk-cursor-theme-size=24", "gtk-cursor-theme-size=32",
+		},
+	}
+
+	for _, tt := range tests {
+		text := loadIniSample(t, tt.sample)
+		if !strings.Contains(text, tt.old) {
+			t.Fatalf("%s: sample doesn't contain %q", tt.sample, tt.old)
+		}
+		d := parseIni(text)
+		d.Set(tt.section, tt.key, tt.value)
+		if got, want := d.String(), strings.Replace(text, tt.old, tt.new, 1); got != want {
+			t.Errorf("%s: Set(%q, %q, %q):\n%q\nwant\n%q", tt.sample, tt.section, tt.key, tt.value, got, want)
+		}
+		if v, _ := parseIni(d.String()).Get(tt.section, tt.key); v != tt.value {
+			t.Errorf("%s: %q after Set and parse = %q, want %q", tt.sample, tt.key, v, tt.value)
+		}
+	}
+
+	d := parseIni("")
+	d.Set("Settings", "gtk-theme-name", "Adwaita")
+	if got := d.String(); got != "[Settings]\ngtk-theme-name=Adwaita\n" {
+		t.Errorf("Set on an empty document = %q", got)
+	}
+}
+
+func TestIniDelete(t *testing.T) {
+	text := loadIniSample(t, "duplicates.ini")
+	d := parseIni(text)
+	d.Delete("Settings", "gtk-theme-name")
+
+	want := strings.Replace(text, "gtk-theme-name=Adwaita\ngtk-theme-name=Adwaita-dark\n", "", 1)
+	if got := d.String(); got != want {
+		t.Errorf("Delete:\n%q\nwant\n%q", got, want)
+	}
+	if v, _ := d.Get("Other", "gtk-theme-name"); v != "ignored" {
+		t.Error("Delete removed the key from another section")
+	}
+
+	d.Delete("Settings", "missing")
+	if got := d.String(); got != want {
+		t.Errorf("Delete of a missing key changed the document:\n%q", got)
+	}
+}
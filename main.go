@@ -26,7 +26,6 @@ const version = "1.1.1"
 
 var (
 	preferences           programSettings
-	originalGtkConfig     []string // we will append not parsed settings.ini lines from here
 	gtkConfig             gtkConfigProperties
 	gtkSettings           *gtk.Settings
 	gsettings             gsettingsValues
@@ -35,6 +34,8 @@ var (
 	cursorThemeNames      map[string]string // theme name to theme folder name
 	viewport              *gtk.Viewport
 	scrolledWindow        *gtk.ScrolledWindow
+	listPane              *gtk.Box // filter bar and scrolledWindow
+	filterBar             *gtk.Box
 	listBox               *gtk.ListBox
 	menuBar               *gtk.MenuBar
 	themeSettingsSelector *gtk.Grid
@@ -44,6 +45,8 @@ var (
 	rowToFocus            *gtk.ListBoxRow
 	voc                   map[string]string
 	gtkThemePaths         map[string]string // theme name to path
+	mainWindow            *gtk.Window
+	firstRun              bool // no preferences file found on startup
 )
 
 type programSettings struct {
@@ -52,9 +55,30 @@ type programSettings struct {
 	ExportIndexTheme               bool `json:"export-index-theme"`
 	ExportXsettingsd               bool `json:"export-xsettingsd"`
 	ExportGtk4Symlinks             bool `json:"export-gtk4-symlinks"`
+	ExportFontconfig               bool `json:"export-fontconfig"`
+	FontconfigFamilies             bool `json:"fontconfig-families"`
+	ExportXresources               bool `json:"export-xresources"`
+	MergeXresources                bool `json:"merge-xresources"`
 	FlatpakExportGTKThemeOverride  bool `json:"flatpak-export-gtk-theme-override"`
 	FlatpakExportIconThemeOverride bool `json:"flatpak-export-icon-theme-override"`
 	FlatpakInstallCurrentGTKTheme  bool `json:"flatpak-install-current-gtk-theme"`
+	FlatpakThemeExtension          bool `json:"flatpak-theme-extension"`
+	FlatpakInstallIconThemes       bool `json:"flatpak-install-icon-themes"`
+	FlatpakGtk4Theme               bool `json:"flatpak-gtk4-theme"`
+	SnapThemes                     bool `json:"snap-themes"`
+
+	// flatpak app ID to theme assignment
+	FlatpakAppThemes map[string]flatpakAppTheme `json:"flatpak-app-themes,omitempty"`
+
+	// global flatpak overrides added by "Style GTK4 / libadwaita apps", taken back when it's unchecked
+	FlatpakGtk4Filesystems   []string `json:"flatpak-gtk4-filesystems,omitempty"`
+	FlatpakColorSchemeEnvSet bool     `json:"flatpak-color-scheme-env-set,omitempty"`
+
+	// Xft DPI set on the font page, 0 if not set; exporters other than settings.ini need it too
+	XftDpi int `json:"xft-dpi,omitempty"`
+
+	// GTK themes the user chose to apply despite the toolkit support warning
+	ThemeSupportConfirmed []string `json:"theme-support-confirmed,omitempty"`
 }
 
 func programSettingsNewWithDefaults() programSettings {
@@ -64,10 +88,18 @@ func programSettingsNewWithDefaults() programSettings {
 	p.ExportIndexTheme = true
 	p.ExportXsettingsd = true
 	p.ExportGtk4Symlinks = true
+	p.ExportFontconfig = false
+	p.FontconfigFamilies = false
+	p.ExportXresources = false
+	p.MergeXresources = false
 
 	p.FlatpakExportGTKThemeOverride = false
 	p.FlatpakExportIconThemeOverride = false
 	p.FlatpakInstallCurrentGTKTheme = false
+	p.FlatpakThemeExtension = false
+	p.FlatpakInstallIconThemes = false
+	p.FlatpakGtk4Theme = false
+	p.SnapThemes = false
 
 	return p
 }
@@ -76,6 +108,7 @@ type gtkConfigProperties struct {
 	themeName                  string
 	iconThemeName              string
 	fontName                   string
+	keyThemeName               string
 	cursorThemeName            string
 	cursorThemeSize            int
 	toolbarStyle               string
@@ -84,6 +117,7 @@ type gtkConfigProperties struct {
 	menuImages                 bool
 	enableEventSounds          bool
 	enableInputFeedbackSounds  bool
+	soundThemeName             string
 	xftAntialias               int
 	fontAntialiasing           string
 	xftDpi                     int
@@ -91,6 +125,11 @@ type gtkConfigProperties struct {
 	xftHintstyle               string
 	xftRgba                    string
 	applicationPreferDarkTheme bool
+	enableAnimations           bool
+	cursorBlink                bool
+	cursorBlinkTime            int
+	cursorBlinkTimeout         int
+	decorationLayout           string
 }
 
 func gtkConfigPropertiesNewWithDefaults() gtkConfigProperties {
@@ -99,6 +138,7 @@ func gtkConfigPropertiesNewWithDefaults() gtkConfigProperties {
 	s.themeName = "Adwaita"
 	s.iconThemeName = "Adwaita"
 	s.fontName = "Sans 10"
+	s.keyThemeName = "Default"
 	s.cursorThemeName = ""
 	s.cursorThemeSize = 0
 	s.toolbarStyle = "GTK_TOOLBAR_ICONS"              // ignored
@@ -107,7 +147,9 @@ func gtkConfigPropertiesNewWithDefaults() gtkConfigProperties {
 	s.menuImages = false                              // deprecated
 	s.enableEventSounds = true
 	s.enableInputFeedbackSounds = true
+	s.soundThemeName = "freedesktop"
 	s.xftAntialias = -1
+	s.xftDpi = -1
 	s.applicationPreferDarkTheme = false
 
 	val, err := getGsettingsValue("org.gnome.desktop.interface", "font-antialiasing")
@@ -120,6 +162,11 @@ func gtkConfigPropertiesNewWithDefaults() gtkConfigProperties {
 	s.xftHinting = -1
 	s.xftHintstyle = "hintmedium"
 	s.xftRgba = "none"
+	s.enableAnimations = true
+	s.cursorBlink = true
+	s.cursorBlinkTime = 1200
+	s.cursorBlinkTimeout = 10
+	s.decorationLayout = "menu:close"
 
 	return s
 }
@@ -129,6 +176,9 @@ type gsettingsValues struct {
 	gtkTheme          string
 	iconTheme         string
 	fontName          string
+	monospaceFontName string
+	documentFontName  string
+	keyTheme          string
 	cursorTheme       string
 	cursorSize        int
 	toolbarStyle      string
@@ -138,9 +188,24 @@ type gsettingsValues struct {
 	fontRgbaOrder     string
 	textScalingFactor float64
 	colorScheme       string
+	// org.gnome.desktop.interface (accessibility)
+	enableAnimations   bool
+	cursorBlink        bool
+	cursorBlinkTime    int
+	cursorBlinkTimeout int
+	overlayScrolling   bool
+	// org.gnome.desktop.a11y.interface
+	highContrast bool
+	// org.gnome.desktop.wm.preferences
+	titlebarFont              string
+	buttonLayout              string
+	actionDoubleClickTitlebar string
+	actionMiddleClickTitlebar string
+	actionRightClickTitlebar  string
 	// org.gnome.desktop.sound
 	eventSounds         bool
 	inputFeedbackSounds bool
+	soundTheme          string
 }
 
 func gsettingsNewWithDefaults() gsettingsValues {
@@ -148,6 +213,9 @@ func gsettingsNewWithDefaults() gsettingsValues {
 	g.gtkTheme = "Adwaita"
 	g.iconTheme = "Adwaita"
 	g.fontName = "Sans 10"
+	g.monospaceFontName = "Monospace 10"
+	g.documentFontName = "Sans 10"
+	g.keyTheme = "Default"
 	g.cursorTheme = "Adwaita"
 	g.cursorSize = 24
 	g.toolbarStyle = "both-horiz"
@@ -158,7 +226,19 @@ func gsettingsNewWithDefaults() gsettingsValues {
 	g.textScalingFactor = 1.0
 	g.eventSounds = true
 	g.inputFeedbackSounds = false
+	g.soundTheme = "freedesktop"
 	g.colorScheme = "default"
+	g.enableAnimations = true
+	g.cursorBlink = true
+	g.cursorBlinkTime = 1200
+	g.cursorBlinkTimeout = 10
+	g.overlayScrolling = true
+	g.highContrast = false
+	g.titlebarFont = "Sans Bold 10"
+	g.buttonLayout = "appmenu:close"
+	g.actionDoubleClickTitlebar = "toggle-maximize"
+	g.actionMiddleClickTitlebar = "none"
+	g.actionRightClickTitlebar = "menu"
 
 	return g
 }
@@ -169,6 +249,12 @@ func displayThemes() {
 
 	listBox = setUpThemeListBox(gsettings.gtkTheme)
 	viewport.Add(listBox)
+	filterBar = setUpThemeFilterBar(themeKindGtk)
+	listPane.PackStart(filterBar, false, false, 0)
+	listPane.ReorderChild(filterBar, 0)
+	filterBar.ShowAll()
+	// settings forms hide the pane
+	listPane.Show()
 	menuBar.Deactivate()
 	if rowToFocus != nil {
 		rowToFocus.GrabFocus()
@@ -192,6 +278,11 @@ func displayIconThemes() {
 
 	listBox = setUpIconThemeListBox(gsettings.iconTheme)
 	viewport.Add(listBox)
+	filterBar = setUpThemeFilterBar(themeKindIcon)
+	listPane.PackStart(filterBar, false, false, 0)
+	listPane.ReorderChild(filterBar, 0)
+	filterBar.ShowAll()
+	listPane.Show()
 	menuBar.Deactivate()
 	if rowToFocus != nil {
 		rowToFocus.GrabFocus()
@@ -210,6 +301,11 @@ func displayCursorThemes() {
 
 	listBox = setUpCursorThemeListBox(gsettings.cursorTheme)
 	viewport.Add(listBox)
+	filterBar = setUpThemeFilterBar(themeKindCursor)
+	listPane.PackStart(filterBar, false, false, 0)
+	listPane.ReorderChild(filterBar, 0)
+	filterBar.ShowAll()
+	listPane.Show()
 	menuBar.Deactivate()
 	if rowToFocus != nil {
 		rowToFocus.GrabFocus()
@@ -232,7 +328,7 @@ func displayFontSettingsForm() {
 	grid.Attach(preview, 0, 1, 1, 1)
 	menuBar.Deactivate()
 	grid.ShowAll()
-	scrolledWindow.Hide()
+	listPane.Hide()
 }
 
 func displayOtherSettingsForm() {
@@ -242,7 +338,47 @@ func displayOtherSettingsForm() {
 	grid.Attach(preview, 0, 1, 1, 1)
 	menuBar.Deactivate()
 	grid.ShowAll()
-	scrolledWindow.Hide()
+	listPane.Hide()
+}
+
+func displayWindowSettingsForm() {
+	destroyContent()
+
+	preview = setUpWindowSettingsForm()
+	grid.Attach(preview, 0, 1, 1, 1)
+	menuBar.Deactivate()
+	grid.ShowAll()
+	listPane.Hide()
+}
+
+func displayFlatpakSettingsForm() {
+	destroyContent()
+
+	preview = setUpFlatpakSettingsForm()
+	grid.Attach(preview, 0, 1, 1, 1)
+	menuBar.Deactivate()
+	grid.ShowAll()
+	listPane.Hide()
+}
+
+func displayAccessibilitySettingsForm() {
+	destroyContent()
+
+	preview = setUpAccessibilitySettingsForm()
+	grid.Attach(preview, 0, 1, 1, 1)
+	menuBar.Deactivate()
+	grid.ShowAll()
+	listPane.Hide()
+}
+
+func displayAdvancedSettingsForm() {
+	destroyContent()
+
+	preview = setUpAdvancedSettingsForm()
+	grid.Attach(preview, 0, 1, 1, 1)
+	menuBar.Deactivate()
+	grid.ShowAll()
+	listPane.Hide()
 }
 
 func displayProgramSettingsForm() {
@@ -252,13 +388,19 @@ func displayProgramSettingsForm() {
 	grid.Attach(preview, 0, 1, 1, 1)
 	menuBar.Deactivate()
 	grid.ShowAll()
-	scrolledWindow.Hide()
+	listPane.Hide()
 }
 
 func destroyContent() {
+	// drop thumbnails pending for the list
+	thumbnailGeneration.Add(1)
 	if listBox != nil {
 		listBox.Destroy()
 	}
+	if filterBar != nil {
+		filterBar.Destroy()
+		filterBar = nil
+	}
 	if preview != nil {
 		preview.Destroy()
 	}
@@ -270,12 +412,61 @@ func destroyContent() {
 	}
 }
 
+func runCommand(args []string) int {
+	switch args[0] {
+	case "uninstall":
+		if len(args) != 2 {
+			fmt.Println("Usage: nwg-look uninstall NAME")
+			return 1
+		}
+		return uninstallTheme(args[1])
+	case "check-theme":
+		if len(args) != 2 {
+			fmt.Println("Usage: nwg-look check-theme NAME")
+			return 1
+		}
+		return checkTheme(args[1])
+	case "import":
+		if len(args) != 2 {
+			fmt.Println("Usage: nwg-look import FILE")
+			return 1
+		}
+		return importSettingsFile(args[1])
+	case "flatpak":
+		if len(args) != 2 {
+			fmt.Println("Usage: nwg-look flatpak list|clean|reinstall")
+			return 1
+		}
+		return flatpakCommand(args[1])
+	case "snap":
+		if len(args) != 2 {
+			fmt.Println("Usage: nwg-look snap report|connect")
+			return 1
+		}
+		return snapCommand(args[1])
+	default:
+		fmt.Printf("Unknown command: %s\n", args[0])
+		flag.Usage()
+		return 1
+	}
+}
+
 func main() {
 	var debug = flag.Bool("d", false, "turn on Debug messages")
 	var displayVersion = flag.Bool("v", false, "display Version information")
 	var applyGs = flag.Bool("a", false, "Apply stored gsetting and quit")
 	var restoreDefaults = flag.Bool("r", false, "Restore default values and quit")
 	var exportConfigs = flag.Bool("x", false, "eXport config files and quit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nCommands:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  uninstall NAME\tRemove a theme installed in user directories\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  check-theme NAME\tShow which toolkits the GTK theme supports\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  import FILE\tImport settings from a settings.ini or gtkrc-2.0 file\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  flatpak list|clean|reinstall\tManage theme copies made for Flatpak apps\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  snap report|connect\tShow or connect theme interfaces of snaps\n")
+	}
 	flag.Parse()
 
 	if *displayVersion {
@@ -329,17 +520,33 @@ func main() {
 			} else {
 				clearGtk4Symlinks()
 			}
+			if preferences.ExportFontconfig {
+				saveFontconfig()
+			}
+			if preferences.ExportXresources {
+				saveXresources()
+			}
 		}
 		os.Exit(0)
 	}
 
 	readGsettings()
 
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
 	if *applyGs || *exportConfigs {
 		if *applyGs {
 			applyGsettingsFromFile()
 		}
 		if *exportConfigs {
+			// keep values that only live in settings.ini, like gtk-xft-dpi
+			if preferences.ExportSettingsIni {
+				loadGtkConfig()
+			}
+			loadXftDpi()
+
 			if preferences.ExportSettingsIni {
 				saveGtkIni3()
 			}
@@ -357,6 +564,12 @@ func main() {
 				linkGtk4Stuff()
 				saveGtkIni4()
 			}
+			if preferences.ExportFontconfig {
+				saveFontconfig()
+			}
+			if preferences.ExportXresources {
+				saveXresources()
+			}
 		}
 		os.Exit(0)
 	}
@@ -369,8 +582,11 @@ func main() {
 	if preferences.ExportSettingsIni {
 		loadGtkConfig()
 	}
+	loadXftDpi()
 
 	gtkSettings, _ = gtk.SettingsGetDefault()
+	// the widget gallery follows the color scheme as well as the theme
+	gtkSettings.SetProperty("gtk-application-prefer-dark-theme", gsettings.colorScheme == "prefer-dark")
 
 	gladeFile := ""
 	for _, d := range dataDirs {
@@ -382,6 +598,7 @@ func main() {
 
 	builder, _ := gtk.BuilderNewFromFile(gladeFile)
 	win, _ := getWindow(builder, "window")
+	mainWindow = win
 
 	win.Connect("destroy", func() {
 		gtk.MainQuit()
@@ -398,6 +615,7 @@ func main() {
 
 	viewport, _ = getViewPort(builder, "viewport-list")
 	scrolledWindow, _ = getScrolledWindow(builder, "scrolled-window")
+	listPane, _ = getBox(builder, "list-pane")
 	grid, _ = getGrid(builder, "grid")
 
 	menuBar, _ = getMenuBar(builder, "menubar")
@@ -426,6 +644,26 @@ func main() {
 	item6.SetLabel(voc["preferences"])
 	item6.Connect("button-release-event", displayProgramSettingsForm)
 
+	item7, _ := getMenuItem(builder, "item-accessibility")
+	item7.SetLabel(voc["accessibility"])
+	item7.Connect("button-release-event", displayAccessibilitySettingsForm)
+
+	item8, _ := getMenuItem(builder, "item-advanced")
+	item8.SetLabel(voc["advanced"])
+	item8.Connect("button-release-event", displayAdvancedSettingsForm)
+
+	item9, _ := getMenuItem(builder, "item-window")
+	item9.SetLabel(voc["window"])
+	item9.Connect("button-release-event", displayWindowSettingsForm)
+
+	item10, _ := getMenuItem(builder, "item-flatpak")
+	item10.SetLabel(voc["flatpak"])
+	item10.Connect("button-release-event", displayFlatpakSettingsForm)
+	if !flatpakAvailable() {
+		item10.SetNoShowAll(true)
+		item10.Hide()
+	}
+
 	btnClose, _ := getButton(builder, "btn-close")
 	btnClose.SetLabel(voc["close"])
 	btnClose.Connect("clicked", func() {
@@ -435,8 +673,13 @@ func main() {
 	btnApply, _ := getButton(builder, "btn-apply")
 	btnApply.SetLabel(voc["apply"])
 	btnApply.Connect("clicked", func() {
+		if !confirmThemeSupport() {
+			return
+		}
 		applyGsettings()
+		applyAdvancedGsettings()
 		saveGsettingsBackup()
+		preferences.XftDpi = max(gtkConfig.xftDpi/1024, 0)
 
 		if preferences.ExportSettingsIni {
 			saveGtkIni3()
@@ -454,6 +697,16 @@ func main() {
 			linkGtk4Stuff()
 			saveGtkIni4()
 		}
+		if preferences.ExportFontconfig {
+			saveFontconfig()
+		} else {
+			clearFontconfig()
+		}
+		if preferences.ExportXresources {
+			saveXresources()
+		} else {
+			clearXresources()
+		}
 		if preferences.FlatpakExportGTKThemeOverride {
 			overrideFlatpakGTKTheme()
 		} else if flatpakAvailable() {
@@ -465,11 +718,34 @@ func main() {
 			unsetFlatpakIconTheme()
 		}
 
+		if len(preferences.FlatpakAppThemes) > 0 && flatpakAvailable() {
+			applyFlatpakAppThemes()
+		}
+
 		if preferences.FlatpakInstallCurrentGTKTheme {
-			if err := stylepak.InstallUserTheme("", nil); err != nil {
+			install := stylepak.InstallUserTheme
+			if preferences.FlatpakThemeExtension {
+				install = stylepak.InstallThemeExtension
+			}
+			if err := install("", nil); err != nil {
 				log.Warnf("failed to install flatpak theme: %s", err)
 			}
 		}
+		if preferences.FlatpakInstallIconThemes {
+			if err := stylepak.InstallUserIconThemes("", "", nil); err != nil {
+				log.Warnf("failed to install flatpak icon themes: %s", err)
+			}
+		}
+		if flatpakAvailable() {
+			if preferences.FlatpakGtk4Theme {
+				applyFlatpakGtk4Theme(nil)
+			} else {
+				revokeFlatpakGtk4Theme(nil)
+			}
+		}
+		if preferences.SnapThemes && snapAvailable() {
+			connectSnapThemes()
+		}
 		savePreferences()
 	})
 
@@ -480,5 +756,12 @@ func main() {
 
 	win.ShowAll()
 
+	// offer settings from LXAppearance & co., instead of whatever gsettings happened to hold
+	if firstRun {
+		if files := importCandidates(); len(files) > 0 && showImportWizard(files) {
+			displayThemes()
+		}
+	}
+
 	gtk.Main()
 }
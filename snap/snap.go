@@ -0,0 +1,154 @@
+// Package snap finds out which themes installed snaps can see through theme content interfaces,
+// and connects the interfaces.
+package snap
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nwg-piotr/nwg-look/stylepak"
+)
+
+// content tags of theme interfaces
+const (
+	GtkThemes   = "gtk-3-themes"
+	IconThemes  = "icon-themes"
+	SoundThemes = "sound-themes"
+)
+
+// ThemeContents lists theme content tags
+var ThemeContents = []string{GtkThemes, IconThemes, SoundThemes}
+
+// Available checks if snapd is installed and running. `snap version` prints "unavailable"
+// as the snapd version if it's not.
+func Available(r stylepak.Runner) bool {
+	if r == nil {
+		r = stylepak.ExecRunner{}
+	}
+	out, err := r.Output("snap", "version")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "snapd" {
+			return fields[1] != "unavailable"
+		}
+	}
+	return false
+}
+
+// Connection is a theme content interface line of `snap connections --all`
+type Connection struct {
+	Content string // content tag
+	Plug    string // snap:plug, empty for slots not connected
+	Slot    string // snap:slot, empty for plugs not connected
+}
+
+func parseConnections(text string) []Connection {
+	var conns []Connection
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		content, ok := strings.CutPrefix(fields[0], "content[")
+		if !ok {
+			continue
+		}
+		content = strings.TrimSuffix(content, "]")
+		if !isThemeContent(content) {
+			continue
+		}
+		c := Connection{Content: content, Plug: fields[1], Slot: fields[2]}
+		if c.Plug == "-" {
+			c.Plug = ""
+		}
+		if c.Slot == "-" {
+			c.Slot = ""
+		}
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func isThemeContent(content string) bool {
+	for _, c := range ThemeContents {
+		if c == content {
+			return true
+		}
+	}
+	return false
+}
+
+// Connections returns theme content interfaces of installed snaps, connected or not
+func Connections(r stylepak.Runner) ([]Connection, error) {
+	if r == nil {
+		r = stylepak.ExecRunner{}
+	}
+	out, err := r.Output("snap", "connections", "--all")
+	if err != nil {
+		return nil, err
+	}
+	return parseConnections(string(out)), nil
+}
+
+// Connect connects the plug to the slot, both given as snap:name
+func Connect(r stylepak.Runner, plug, slot string) error {
+	if r == nil {
+		r = stylepak.ExecRunner{}
+	}
+	return r.Run("snap", "connect", plug, slot)
+}
+
+// snapName returns the snap part of snap:plug or snap:slot
+func snapName(ref string) string {
+	name, _, _ := strings.Cut(ref, ":")
+	return name
+}
+
+// ThemeSnap is an installed snap that provides themes
+type ThemeSnap struct {
+	Name   string
+	Themes map[string][]string // theme names by content tag
+}
+
+// ThemeSnaps returns installed snaps with theme content slots, like gtk-common-themes, sorted by name
+func ThemeSnaps(conns []Connection) []ThemeSnap {
+	snaps := make(map[string]ThemeSnap)
+	seen := make(map[string]bool)
+	for _, c := range conns {
+		if c.Slot == "" || seen[c.Content+" "+c.Slot] {
+			continue
+		}
+		seen[c.Content+" "+c.Slot] = true
+
+		name := snapName(c.Slot)
+		s, ok := snaps[name]
+		if !ok {
+			s = ThemeSnap{Name: name, Themes: make(map[string][]string)}
+		}
+		for _, t := range providedThemes(name, c.Content) {
+			if !contains(s.Themes[c.Content], t) {
+				s.Themes[c.Content] = append(s.Themes[c.Content], t)
+			}
+		}
+		snaps[name] = s
+	}
+
+	var list []ThemeSnap
+	for _, s := range snaps {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func contains(slice []string, val string) bool {
+	for _, item := range slice {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package snap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records commands, answers Output from canned outputs, and fails Run for the given commands
+type fakeRunner struct {
+	outputs map[string]string // command line to output
+	fail    map[string]bool   // command lines Run fails
+	calls   []string
+}
+
+func (r *fakeRunner) Run(name string, args ...string) error {
+	call := strings.Join(append([]string{name}, args...), " ")
+	r.calls = append(r.calls, call)
+	if r.fail[call] {
+		return errors.New("command failed: " + call)
+	}
+	return nil
+}
+
+func (r *fakeRunner) Output(name string, args ...string) ([]byte, error) {
+	call := strings.Join(append([]string{name}, args...), " ")
+	r.calls = append(r.calls, call)
+	out, ok := r.outputs[call]
+	if !ok {
+		return nil, errors.New("unexpected command: " + call)
+	}
+	return []byte(out), nil
+}
+
+const sampleConnections = `Interface               Plug                        Slot                            Notes
+content[gnome-42-2204]  firefox:gnome-42-2204       gnome-42-2204:gnome-42-2204     -
+content[gtk-3-themes]   firefox:gtk-3-themes        gtk-common-themes:gtk-3-themes  -
+content[gtk-3-themes]   -                           yaru-themes:gtk-3-themes        -
+content[icon-themes]    firefox:icon-themes         gtk-common-themes:icon-themes   -
+content[sound-themes]   firefox:sound-themes        -                               -
+desktop                 firefox:desktop             :desktop                        -
+`
+
+func TestParseConnections(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Connection
+	}{
+		{"empty", "", nil},
+		{"header only", "Interface  Plug  Slot  Notes\n", nil},
+		{"sample", sampleConnections, []Connection{
+			{Content: GtkThemes, Plug: "firefox:gtk-3-themes", Slot: "gtk-common-themes:gtk-3-themes"},
+			{Content: GtkThemes, Slot: "yaru-themes:gtk-3-themes"},
+			{Content: IconThemes, Plug: "firefox:icon-themes", Slot: "gtk-common-themes:icon-themes"},
+			{Content: SoundThemes, Plug: "firefox:sound-themes"},
+		}},
+	}
+	for _, tt := range tests {
+		if got := parseConnections(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseConnections() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// setUpMountDir mounts fake snaps shipping the given themes, by snap and theme directory
+func setUpMountDir(t *testing.T, snaps map[string]map[string][]string) {
+	old := MountDir
+	MountDir = t.TempDir()
+	t.Cleanup(func() { MountDir = old })
+	for snap, dirs := range snaps {
+		for dir, themes := range dirs {
+			for _, theme := range themes {
+				if err := os.MkdirAll(filepath.Join(MountDir, snap, "current", dir, theme), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	setUpMountDir(t, map[string]map[string][]string{
+		"gtk-common-themes": {"share/themes": {"Adwaita", "Yaru"}, "share/icons": {"Adwaita", "Yaru"}},
+		"yaru-themes":       {"themes": {"Yaru-dark"}},
+	})
+	conns := parseConnections(sampleConnections)
+
+	tests := []struct {
+		name   string
+		themes Themes
+		want   []Visibility
+	}{
+		{"visible", Themes{Gtk: "Yaru", Icon: "Adwaita", Cursor: "Adwaita"}, []Visibility{
+			{Plug: "firefox:gtk-3-themes", Content: GtkThemes, Theme: "Yaru", Visible: true},
+			{Plug: "firefox:icon-themes", Content: IconThemes, Theme: "Adwaita", Visible: true},
+		}},
+		{"not connected", Themes{Gtk: "Yaru-dark", Sound: "freedesktop"}, []Visibility{
+			{Plug: "firefox:gtk-3-themes", Content: GtkThemes, Theme: "Yaru-dark",
+				Providers: []string{"yaru-themes:gtk-3-themes"}},
+			{Plug: "firefox:sound-themes", Content: SoundThemes, Theme: "freedesktop"},
+		}},
+		{"not provided", Themes{Icon: "Papirus", Cursor: "Yaru"}, []Visibility{
+			{Plug: "firefox:icon-themes", Content: IconThemes, Theme: "Papirus"},
+			{Plug: "firefox:icon-themes", Content: IconThemes, Theme: "Yaru", Visible: true},
+		}},
+		{"nothing selected", Themes{}, nil},
+	}
+	for _, tt := range tests {
+		if got := Check(conns, tt.themes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Check() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConnectMissing(t *testing.T) {
+	vis := []Visibility{
+		{Plug: "firefox:gtk-3-themes", Content: GtkThemes, Theme: "Yaru", Visible: true},
+		{Plug: "firefox:gtk-3-themes", Content: GtkThemes, Theme: "Yaru-dark",
+			Providers: []string{"yaru-themes:gtk-3-themes", "other-themes:gtk-3-themes"}},
+		{Plug: "firefox:sound-themes", Content: SoundThemes, Theme: "freedesktop"},
+		{Plug: "gimp:icon-themes", Content: IconThemes, Theme: "Yaru",
+			Providers: []string{"gtk-common-themes:icon-themes"}},
+	}
+	r := &fakeRunner{fail: map[string]bool{"snap connect gimp:icon-themes gtk-common-themes:icon-themes": true}}
+
+	done, errs := ConnectMissing(r, vis)
+	wantCalls := []string{
+		"snap connect firefox:gtk-3-themes yaru-themes:gtk-3-themes",
+		"snap connect gimp:icon-themes gtk-common-themes:icon-themes",
+	}
+	if !reflect.DeepEqual(r.calls, wantCalls) {
+		t.Errorf("calls = %q, want %q", r.calls, wantCalls)
+	}
+	if want := []string{"firefox:gtk-3-themes yaru-themes:gtk-3-themes"}; !reflect.DeepEqual(done, want) {
+		t.Errorf("done = %q, want %q", done, want)
+	}
+	if len(errs) != 1 {
+		t.Errorf("errors = %v, want 1", errs)
+	}
+}
+
+func TestStoreCandidates(t *testing.T) {
+	tests := []struct {
+		content string
+		theme   string
+		want    []string
+	}{
+		{GtkThemes, "Yaru-dark", []string{"gtk-theme-yaru-dark", "gtk-theme-yaru"}},
+		{IconThemes, "Papirus", []string{"icon-theme-papirus"}},
+		{GtkThemes, "Arc_Dark (GTK 3)", []string{"gtk-theme-arc-dark-gtk-3", "gtk-theme-arc-dark-gtk",
+			"gtk-theme-arc-dark", "gtk-theme-arc"}},
+		{SoundThemes, "--", nil},
+	}
+	for _, tt := range tests {
+		if got := storeCandidates(tt.content, tt.theme); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("storeCandidates(%s, %q) = %q, want %q", tt.content, tt.theme, got, tt.want)
+		}
+	}
+}
+
+func TestStoreSnap(t *testing.T) {
+	r := &fakeRunner{outputs: map[string]string{"snap info gtk-theme-yaru": "name: gtk-theme-yaru\n"}}
+	if got := StoreSnap(r, GtkThemes, "Yaru-dark"); got != "gtk-theme-yaru" {
+		t.Errorf("StoreSnap(Yaru-dark) = %q, want %q", got, "gtk-theme-yaru")
+	}
+	if got := StoreSnap(r, IconThemes, "Papirus"); got != "" {
+		t.Errorf("StoreSnap(Papirus) = %q, want none", got)
+	}
+}
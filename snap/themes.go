@@ -0,0 +1,193 @@
+package snap
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nwg-piotr/nwg-look/stylepak"
+)
+
+// theme directories in theme snaps, relative to the snap, by content tag
+var themeDirs = map[string][]string{
+	GtkThemes:   {"share/themes", "themes"},
+	IconThemes:  {"share/icons", "icons"},
+	SoundThemes: {"share/sounds", "sounds"},
+}
+
+// store snap name prefixes snapd uses for themes, by content tag
+var storePrefixes = map[string]string{
+	GtkThemes:   "gtk-theme-",
+	IconThemes:  "icon-theme-",
+	SoundThemes: "sound-theme-",
+}
+
+// MountDir is where snaps are mounted. Distributions other than Ubuntu use /var/lib/snapd/snap.
+var MountDir = mountDir()
+
+func mountDir() string {
+	for _, d := range []string{"/snap", "/var/lib/snapd/snap"} {
+		if fi, err := os.Stat(d); err == nil && fi.IsDir() {
+			return d
+		}
+	}
+	return "/snap"
+}
+
+// providedThemes returns names of themes the snap ships for the content tag
+func providedThemes(snap, content string) []string {
+	var themes []string
+	for _, dir := range themeDirs[content] {
+		entries, err := os.ReadDir(filepath.Join(MountDir, snap, "current", dir))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() && !contains(themes, e.Name()) {
+				themes = append(themes, e.Name())
+			}
+		}
+	}
+	sort.Strings(themes)
+	return themes
+}
+
+// Themes selected in nwg-look. Cursor themes are icon themes.
+type Themes struct {
+	Gtk    string
+	Icon   string
+	Cursor string
+	Sound  string
+}
+
+// byContent returns selected themes the snaps need for the content tag
+func (t Themes) byContent(content string) []string {
+	var themes []string
+	switch content {
+	case GtkThemes:
+		themes = []string{t.Gtk}
+	case IconThemes:
+		themes = []string{t.Icon, t.Cursor}
+	case SoundThemes:
+		themes = []string{t.Sound}
+	}
+
+	var list []string
+	for _, theme := range themes {
+		if theme != "" && !contains(list, theme) {
+			list = append(list, theme)
+		}
+	}
+	return list
+}
+
+// Visibility tells if a plug of an installed snap gives it access to a selected theme
+type Visibility struct {
+	Plug      string // snap:plug
+	Content   string
+	Theme     string
+	Visible   bool
+	Providers []string // snap:slot of slots the plug is not connected to, that provide the theme
+}
+
+// Check returns visibility of selected themes for every theme plug of installed snaps
+func Check(conns []Connection, themes Themes) []Visibility {
+	provided := make(map[string][]string) // by content and snap:slot
+	slots := make(map[string][]string)    // by content
+	connected := make(map[string][]string)
+	var plugs []Connection
+
+	for _, c := range conns {
+		if c.Slot != "" {
+			key := c.Content + " " + c.Slot
+			if _, ok := provided[key]; !ok {
+				provided[key] = providedThemes(snapName(c.Slot), c.Content)
+				slots[c.Content] = append(slots[c.Content], c.Slot)
+			}
+		}
+		if c.Plug == "" {
+			continue
+		}
+		key := c.Content + " " + c.Plug
+		if _, ok := connected[key]; !ok {
+			connected[key] = nil
+			plugs = append(plugs, Connection{Content: c.Content, Plug: c.Plug})
+		}
+		if c.Slot != "" {
+			connected[key] = append(connected[key], c.Slot)
+		}
+	}
+
+	var vis []Visibility
+	for _, p := range plugs {
+		for _, theme := range themes.byContent(p.Content) {
+			v := Visibility{Plug: p.Plug, Content: p.Content, Theme: theme}
+			for _, slot := range slots[p.Content] {
+				if !contains(provided[p.Content+" "+slot], theme) {
+					continue
+				}
+				if contains(connected[p.Content+" "+p.Plug], slot) {
+					v.Visible = true
+				} else {
+					v.Providers = append(v.Providers, slot)
+				}
+			}
+			vis = append(vis, v)
+		}
+	}
+	sort.SliceStable(vis, func(i, j int) bool { return vis[i].Plug < vis[j].Plug })
+	return vis
+}
+
+// ConnectMissing connects plugs that can't see a selected theme to the first slot providing it.
+// It returns plugs it has connected, and errors of those it couldn't.
+func ConnectMissing(r stylepak.Runner, vis []Visibility) ([]string, []error) {
+	var done []string
+	var errs []error
+	for _, v := range vis {
+		if v.Visible || len(v.Providers) == 0 {
+			continue
+		}
+		if err := Connect(r, v.Plug, v.Providers[0]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		done = append(done, v.Plug+" "+v.Providers[0])
+	}
+	return done, errs
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// storeCandidates returns store snap names that may provide the theme, the way snapd looks them up:
+// "Yaru-dark" gives gtk-theme-yaru-dark and gtk-theme-yaru
+func storeCandidates(content, theme string) []string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(theme), "-")
+	name = strings.Trim(name, "-")
+
+	var candidates []string
+	for name != "" {
+		candidates = append(candidates, storePrefixes[content]+name)
+		i := strings.LastIndexByte(name, '-')
+		if i < 0 {
+			break
+		}
+		name = strings.TrimRight(name[:i], "-")
+	}
+	return candidates
+}
+
+// StoreSnap returns the name of a store snap that provides the theme, or an empty string
+func StoreSnap(r stylepak.Runner, content, theme string) string {
+	if r == nil {
+		r = stylepak.ExecRunner{}
+	}
+	for _, name := range storeCandidates(content, theme) {
+		if _, err := r.Output("snap", "info", name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
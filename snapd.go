@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/nwg-piotr/nwg-look/snap"
+	"github.com/nwg-piotr/nwg-look/stylepak"
+	log "github.com/sirupsen/logrus"
+)
+
+// snapAvailable checks if snapd is installed and running
+func snapAvailable() bool {
+	if _, err := exec.LookPath("snap"); err != nil {
+		return false
+	}
+	return snap.Available(nil)
+}
+
+// selectedSnapThemes returns themes snaps should see
+func selectedSnapThemes() snap.Themes {
+	return snap.Themes{
+		Gtk:    gsettings.gtkTheme,
+		Icon:   gsettings.iconTheme,
+		Cursor: gsettings.cursorTheme,
+		Sound:  gsettings.soundTheme,
+	}
+}
+
+// snapReport describes theme snaps, and visibility of selected themes in installed snaps.
+// With store on, store snaps are looked up for themes no installed snap provides.
+func snapReport(r stylepak.Runner, store bool) ([]string, error) {
+	conns, err := snap.Connections(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	lines = append(lines, voc["snap-theme-snaps"]+":")
+	themeSnaps := snap.ThemeSnaps(conns)
+	if len(themeSnaps) == 0 {
+		lines = append(lines, "  "+voc["none"])
+	}
+	for _, s := range themeSnaps {
+		var counts []string
+		for _, content := range snap.ThemeContents {
+			if n := len(s.Themes[content]); n > 0 {
+				counts = append(counts, fmt.Sprintf("%s: %v", content, n))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("  %s (%s)", s.Name, strings.Join(counts, ", ")))
+	}
+
+	lines = append(lines, "", voc["snap-theme-plugs"]+":")
+	vis := snap.Check(conns, selectedSnapThemes())
+	if len(vis) == 0 {
+		lines = append(lines, "  "+voc["none"])
+	}
+	storeSnaps := make(map[string]string)
+	for _, v := range vis {
+		var status string
+		switch {
+		case v.Visible:
+			status = voc["snap-visible"]
+		case len(v.Providers) > 0:
+			status = fmt.Sprintf("%s %s", voc["snap-not-connected"], strings.Join(v.Providers, ", "))
+		default:
+			status = voc["snap-not-provided"]
+			if store {
+				key := v.Content + " " + v.Theme
+				if _, ok := storeSnaps[key]; !ok {
+					storeSnaps[key] = snap.StoreSnap(r, v.Content, v.Theme)
+				}
+				if storeSnaps[key] != "" {
+					status += fmt.Sprintf(", %s: snap install %s", voc["snap-in-store"], storeSnaps[key])
+				}
+			}
+		}
+		lines = append(lines, fmt.Sprintf("  %s  %s: %s", v.Plug, v.Theme, status))
+	}
+	return lines, nil
+}
+
+// connectSnapThemes connects theme plugs of installed snaps to slots that provide selected themes
+func connectSnapThemes() {
+	conns, err := snap.Connections(nil)
+	if err != nil {
+		log.Warnf("Couldn't read snap connections: %s", err)
+		return
+	}
+	done, errs := snap.ConnectMissing(nil, snap.Check(conns, selectedSnapThemes()))
+	for _, d := range done {
+		log.Infof("Connected snap interface: %s", d)
+	}
+	for _, err := range errs {
+		log.Warnf("failed to connect snap interface: %s", err)
+	}
+}
+
+// snapReportText returns snapReport as text, or the error
+func snapReportText(store bool) string {
+	lines, err := snapReport(nil, store)
+	if err != nil {
+		return err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// showSnapReport displays snapReport in a dialog. Store lookups are network calls: the report of installed
+// snaps is shown first, and replaced when they are done in the background.
+func showSnapReport() {
+	dialog := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL, gtk.MESSAGE_INFO, gtk.BUTTONS_CLOSE, "%s", voc["snap-report"])
+	dialog.FormatSecondaryText("%s\n\n%s", snapReportText(false), voc["snap-store-lookup"])
+
+	// only touched on the main thread
+	closed := false
+	go func() {
+		text := snapReportText(true)
+		glib.IdleAdd(func() {
+			if !closed {
+				dialog.FormatSecondaryText("%s", text)
+			}
+		})
+	}()
+	dialog.Run()
+	closed = true
+	dialog.Destroy()
+}
+
+// snapCommand handles `nwg-look snap report|connect`
+func snapCommand(action string) int {
+	if !snapAvailable() {
+		fmt.Println("snapd is not available")
+		return 1
+	}
+
+	switch action {
+	case "report":
+		lines, err := snapReport(nil, true)
+		if err != nil {
+			fmt.Printf("Couldn't read snap connections: %s\n", err)
+			return 1
+		}
+		fmt.Println(strings.Join(lines, "\n"))
+	case "connect":
+		connectSnapThemes()
+	default:
+		fmt.Println("Usage: nwg-look snap report|connect")
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+// freedesktop sound themes
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// getSoundThemes returns map[folder name]display name of sound themes found in sounds/ of data dirs
+func getSoundThemes() map[string]string {
+	themes := make(map[string]string)
+	for _, d := range dataDirs {
+		soundsDir := filepath.Join(d, "sounds")
+		files, err := listFiles(soundsDir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if !f.IsDir() {
+				continue
+			}
+			if _, ok := themes[f.Name()]; ok {
+				continue
+			}
+			values, err := parseIndexTheme(filepath.Join(soundsDir, f.Name(), "index.theme"))
+			if err != nil {
+				continue
+			}
+			if strings.ToLower(values["Sound Theme/Hidden"]) == "true" {
+				log.Debugf("Hidden sound theme: %s", f.Name())
+				continue
+			}
+			name := values["Sound Theme/Name"]
+			if name == "" {
+				name = f.Name()
+			}
+			themes[f.Name()] = name
+			log.Debugf("Sound theme found: '%s' at '%s'", name, filepath.Join(soundsDir, f.Name()))
+		}
+	}
+	return themes
+}
+
+// sortedSoundThemeIDs returns folder names sorted by display name
+func sortedSoundThemeIDs(themes map[string]string) []string {
+	var ids []string
+	for id := range themes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return strings.ToLower(themes[ids[i]]) < strings.ToLower(themes[ids[j]])
+	})
+	return ids
+}
+
+func canberraAvailable() bool {
+	_, err := exec.LookPath("canberra-gtk-play")
+	return err == nil
+}
+
+// playSoundThemeSample plays a sample event from the given sound theme, if canberra-gtk-play is installed
+func playSoundThemeSample(themeName string) {
+	cmd := exec.Command("canberra-gtk-play", "--id=dialog-information",
+		"--description=nwg-look sound theme preview",
+		"--property=canberra.xdg-theme.name="+themeName)
+	log.Debugf("Playing sample: %s", cmd.String())
+	if err := cmd.Start(); err != nil {
+		log.Warnf("Couldn't play sample: %s", err)
+		return
+	}
+	go cmd.Wait()
+}
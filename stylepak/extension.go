@@ -0,0 +1,343 @@
+package stylepak
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	gtk3ThemeExtensionPrefix = "org.gtk.Gtk3theme."
+	// extension point the theme extension plugs into, in runtime metadata
+	gtk3ThemeExtensionPoint = "Extension org.gtk.Gtk3theme"
+	// version of the org.gtk.Gtk3theme extension point, for runtimes that don't tell
+	gtk3ThemeExtensionBranch = "3.22"
+	// local repository remote all theme extensions are installed from
+	extensionRemote = "nwg-look-stylepak"
+)
+
+// extensionTarget is an architecture and org.gtk.Gtk3theme extension point version of installed runtimes
+type extensionTarget struct {
+	arch    string
+	version string // branch of the extension, e.g. "3.22"
+}
+
+func extensionID(theme string) string {
+	return gtk3ThemeExtensionPrefix + theme
+}
+
+func extensionRef(theme string, t extensionTarget) string {
+	return fmt.Sprintf("runtime/%s/%s/%s", extensionID(theme), t.arch, t.version)
+}
+
+func extensionRepoDir(dataHome string) string {
+	return filepath.Join(dataHome, "nwg-look", "stylepak", "repo")
+}
+
+// extensionStampsFile keeps content hashes of exported refs, to skip exporting and installing unchanged ones
+func extensionStampsFile(dataHome string) string {
+	return filepath.Join(dataHome, "nwg-look", "stylepak", "exports.json")
+}
+
+func readExtensionStamps(path string) map[string]string {
+	stamps := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return stamps
+	}
+	if err := json.Unmarshal(data, &stamps); err != nil || stamps == nil {
+		return make(map[string]string)
+	}
+	return stamps
+}
+
+func writeExtensionStamps(path string, stamps map[string]string) error {
+	data, err := json.MarshalIndent(stamps, "", " ")
+	if err != nil {
+		return fmt.Errorf("encode export stamps: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write export stamps: %w", err)
+	}
+	return nil
+}
+
+// parseExtensionVersions returns versions of the org.gtk.Gtk3theme extension point in runtime metadata.
+// Runtimes declare it with either `version` or a `;` separated `versions` list.
+func parseExtensionVersions(metadata string) []string {
+	var versions []string
+	section := ""
+	for _, line := range strings.Split(metadata, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if section != gtk3ThemeExtensionPoint || !ok || (key != "version" && key != "versions") {
+			continue
+		}
+		for _, v := range strings.Split(value, ";") {
+			if v = strings.TrimSpace(v); v != "" && !slices.Contains(versions, v) {
+				versions = append(versions, v)
+			}
+		}
+	}
+	return versions
+}
+
+// extensionTargets returns arch and version pairs of the org.gtk.Gtk3theme extension point of installed
+// runtimes. If no runtime declares it, the default branch is used for all flatpak architectures.
+func extensionTargets(r Runner) ([]extensionTarget, error) {
+	out, err := r.Output("flatpak", "list", "--runtime", "--columns=ref")
+	if err != nil {
+		return nil, fmt.Errorf("list flatpak runtimes: %w", err)
+	}
+
+	var targets []extensionTarget
+	for _, ref := range strings.Split(string(out), "\n") {
+		ref = strings.TrimSpace(ref)
+		parts := strings.Split(ref, "/")
+		// theme extensions themselves, locales, GL drivers and such don't load themes
+		if len(parts) != 3 || strings.HasPrefix(parts[0], gtk3ThemeExtensionPrefix) {
+			continue
+		}
+		metadata, err := r.Output("flatpak", "info", "--show-metadata", ref)
+		if err != nil {
+			log.Debugf("Couldn't read metadata of %s: %s", ref, err)
+			continue
+		}
+		for _, v := range parseExtensionVersions(string(metadata)) {
+			t := extensionTarget{arch: parts[1], version: v}
+			if !slices.Contains(targets, t) {
+				targets = append(targets, t)
+			}
+		}
+	}
+	if len(targets) > 0 {
+		sort.Slice(targets, func(i, j int) bool {
+			if targets[i].arch != targets[j].arch {
+				return targets[i].arch < targets[j].arch
+			}
+			return targets[i].version < targets[j].version
+		})
+		return targets, nil
+	}
+
+	arches, err := getFlatpakArchitectures(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, arch := range arches {
+		targets = append(targets, extensionTarget{arch: arch, version: gtk3ThemeExtensionBranch})
+	}
+	return targets, nil
+}
+
+// treeHash returns a hash of paths, contents and link targets of files in the dir
+func treeHash(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk path %q: %w", path, err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("compute relative path: %w", err)
+		}
+		entry := manifestDir
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("read symlink %q: %w", path, err)
+			}
+			entry = manifestLink + link
+		case !info.IsDir():
+			if entry, err = fileHash(path); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", rel, entry)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildExtensionRoot lays out a flatpak build directory for the theme extension in root:
+// the metadata file, and the gtk-3.x directory src of the theme as files/gtk-3.0
+func buildExtensionRoot(theme, src, root string) error {
+	log.Info("Using theme dir:", src)
+	gtkDir := filepath.Join(root, "files", "gtk-3.0")
+	if err := os.MkdirAll(gtkDir, 0o755); err != nil {
+		return fmt.Errorf("create dir %q: %w", gtkDir, err)
+	}
+	if err := copyDir(src, gtkDir); err != nil {
+		return fmt.Errorf("copy theme dir %q: %w", src, err)
+	}
+
+	appdataDir := filepath.Join(root, "files", "share", "appdata")
+	if err := os.MkdirAll(appdataDir, 0o755); err != nil {
+		return fmt.Errorf("create dir %q: %w", appdataDir, err)
+	}
+	appdata := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<component type="runtime">
+  <id>%s</id>
+  <metadata_license>CC0-1.0</metadata_license>
+  <name>%s Gtk theme</name>
+  <summary>%s Gtk theme (generated by nwg-look)</summary>
+</component>
+`, extensionID(theme), theme, theme)
+	if err := os.WriteFile(filepath.Join(appdataDir, extensionID(theme)+".appdata.xml"), []byte(appdata), 0o644); err != nil {
+		return fmt.Errorf("write appdata: %w", err)
+	}
+
+	metadata := fmt.Sprintf("[Runtime]\nname=%s\n", extensionID(theme))
+	if err := os.WriteFile(filepath.Join(root, "metadata"), []byte(metadata), 0o644); err != nil {
+		return fmt.Errorf("write metadata: %w", err)
+	}
+
+	return nil
+}
+
+// InstallThemeExtension builds an org.gtk.Gtk3theme.<theme> runtime extension for every flatpak
+// architecture into a local repository, and installs it for the user. Unlike InstallUserTheme,
+// flatpak apps need no filesystem override to use the theme.
+func InstallThemeExtension(theme string, runner Runner) error {
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("resolve home directory: %w", err)
+	}
+
+	dataHome := getenvDefault("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+
+	if theme == "" {
+		theme, err = getCurrentGTKTheme(runner)
+		if err != nil {
+			return fmt.Errorf("resolve current GTK theme: %w", err)
+		}
+	}
+
+	if err := validateTheme(theme); err != nil {
+		return err
+	}
+
+	log.Info("Building theme extension:", extensionID(theme))
+
+	themePath, err := findThemePath(theme, dataHome, home)
+	if err != nil {
+		return fmt.Errorf("locate theme %q: %w", theme, err)
+	}
+	log.Info("Found theme located at:", themePath)
+
+	targets, err := extensionTargets(runner)
+	if err != nil {
+		return fmt.Errorf("detect flatpak runtimes: %w", err)
+	}
+
+	repoDir := extensionRepoDir(dataHome)
+	if err := os.MkdirAll(filepath.Dir(repoDir), 0o755); err != nil {
+		return fmt.Errorf("create dir %q: %w", filepath.Dir(repoDir), err)
+	}
+	stampsFile := extensionStampsFile(dataHome)
+	stamps := readExtensionStamps(stampsFile)
+
+	// build roots and their hashes by theme dir, as runtime versions may share one
+	roots := make(map[string]string)
+	hashes := make(map[string]string)
+	defer func() {
+		for _, root := range roots {
+			clean(root)
+		}
+	}()
+
+	var pending []extensionTarget
+	exported := make(map[string]string) // ref to hash of exported content
+	for _, t := range targets {
+		minor := 0
+		if _, m, ok := strings.Cut(t.version, "."); ok {
+			minor, _ = strconv.Atoi(m)
+		}
+		src, err := themeDirForVersion(themePath, minor)
+		if err != nil {
+			return fmt.Errorf("detect GTK version of %q: %w", themePath, err)
+		}
+
+		root, ok := roots[src]
+		if !ok {
+			root, err = os.MkdirTemp("", "nwg-look-stylepak-")
+			if err != nil {
+				return fmt.Errorf("create build dir: %w", err)
+			}
+			roots[src] = root
+			if err := buildExtensionRoot(theme, src, root); err != nil {
+				return err
+			}
+			if hashes[root], err = treeHash(root); err != nil {
+				return err
+			}
+		}
+
+		ref := extensionRef(theme, t)
+		if stamps[ref] == hashes[root] && runner.Run("flatpak", "info", "--user", ref) == nil {
+			log.Info("Up to date:", ref)
+			continue
+		}
+
+		// build-export creates the repository on first use, and updates its summary
+		if err := runner.Run("flatpak", "build-export", "--runtime", "--files=files",
+			"--arch="+t.arch, repoDir, root, t.version,
+		); err != nil {
+			return fmt.Errorf("export %s: %w", ref, err)
+		}
+		log.Info("Exported:", ref)
+		exported[ref] = hashes[root]
+		pending = append(pending, t)
+	}
+
+	if len(pending) == 0 {
+		log.Infof("Theme extension %s is up to date", extensionID(theme))
+		return nil
+	}
+
+	if err := runner.Run("flatpak", "remote-add", "--user", "--if-not-exists", "--no-gpg-verify",
+		extensionRemote, "file://"+repoDir,
+	); err != nil {
+		return fmt.Errorf("add flatpak remote %s: %w", extensionRemote, err)
+	}
+
+	for _, t := range pending {
+		ref := extensionRef(theme, t)
+		if err := runner.Run("flatpak", "install", "--user", "--noninteractive", "--reinstall", "-y",
+			extensionRemote, ref,
+		); err != nil {
+			delete(stamps, ref)
+			writeExtensionStamps(stampsFile, stamps)
+			return fmt.Errorf("install %s: %w", ref, err)
+		}
+		log.Info("Installed:", ref)
+		stamps[ref] = exported[ref]
+	}
+	if err := writeExtensionStamps(stampsFile, stamps); err != nil {
+		return err
+	}
+
+	log.Infof("Successfully installed theme extension %s", extensionID(theme))
+	return nil
+}
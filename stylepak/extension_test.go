@@ -0,0 +1,194 @@
+package stylepak
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records commands, answers Output from canned outputs, and keeps track of installed refs
+type fakeRunner struct {
+	outputs   map[string]string // command line to output
+	installed map[string]bool
+	calls     [][]string
+}
+
+func (r *fakeRunner) record(name string, args []string) []string {
+	call := []string{name}
+	for _, a := range args {
+		// build dirs are temporary
+		if strings.HasPrefix(filepath.Base(a), "nwg-look-stylepak-") {
+			a = "<root>"
+		}
+		call = append(call, a)
+	}
+	r.calls = append(r.calls, call)
+	return call
+}
+
+func (r *fakeRunner) Run(name string, args ...string) error {
+	call := r.record(name, args)
+	switch {
+	case len(call) == 4 && call[1] == "info" && call[2] == "--user":
+		if !r.installed[call[3]] {
+			return errors.New("not installed")
+		}
+	case len(call) > 2 && call[1] == "install":
+		r.installed[call[len(call)-1]] = true
+	}
+	return nil
+}
+
+func (r *fakeRunner) Output(name string, args ...string) ([]byte, error) {
+	call := r.record(name, args)
+	out, ok := r.outputs[strings.Join(call, " ")]
+	if !ok {
+		return nil, errors.New("unexpected command")
+	}
+	return []byte(out), nil
+}
+
+// commands returns recorded calls of the flatpak subcommand
+func (r *fakeRunner) commands(subcommand string) [][]string {
+	var calls [][]string
+	for _, c := range r.calls {
+		if len(c) > 1 && c[1] == subcommand {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+const platformMetadata = `[Runtime]
+name=org.gnome.Platform
+
+[Extension org.gtk.Gtk3theme]
+directory=share/runtime/gtk-3.0
+version=3.22
+subdirectories=true
+`
+
+func setUpExtensionTest(t *testing.T) (string, *fakeRunner) {
+	home := t.TempDir()
+	dataHome := filepath.Join(home, ".local", "share")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	for _, dir := range []string{"gtk-3.0", "gtk-3.20", "gtk-2.0"} {
+		path := filepath.Join(dataHome, "themes", "Test", dir)
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "gtk.css"), []byte(dir), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"flatpak list --runtime --columns=ref": "org.gnome.Platform/x86_64/46\n" +
+				"org.gnome.Platform/aarch64/46\n" +
+				"org.gtk.Gtk3theme.Test/x86_64/3.22\n",
+			"flatpak info --show-metadata org.gnome.Platform/x86_64/46":  platformMetadata,
+			"flatpak info --show-metadata org.gnome.Platform/aarch64/46": platformMetadata,
+		},
+		installed: make(map[string]bool),
+	}
+	return dataHome, r
+}
+
+func TestInstallThemeExtension(t *testing.T) {
+	dataHome, r := setUpExtensionTest(t)
+	repoDir := extensionRepoDir(dataHome)
+
+	if err := InstallThemeExtension("Test", r); err != nil {
+		t.Fatal(err)
+	}
+
+	wantExport := [][]string{
+		{"flatpak", "build-export", "--runtime", "--files=files", "--arch=aarch64", repoDir, "<root>", "3.22"},
+		{"flatpak", "build-export", "--runtime", "--files=files", "--arch=x86_64", repoDir, "<root>", "3.22"},
+	}
+	if got := r.commands("build-export"); !reflect.DeepEqual(got, wantExport) {
+		t.Errorf("build-export calls:\n%q\nwant\n%q", got, wantExport)
+	}
+
+	wantRemote := [][]string{
+		{"flatpak", "remote-add", "--user", "--if-not-exists", "--no-gpg-verify", extensionRemote, "file://" + repoDir},
+	}
+	if got := r.commands("remote-add"); !reflect.DeepEqual(got, wantRemote) {
+		t.Errorf("remote-add calls:\n%q\nwant\n%q", got, wantRemote)
+	}
+
+	wantInstall := [][]string{
+		{"flatpak", "install", "--user", "--noninteractive", "--reinstall", "-y", extensionRemote,
+			"runtime/org.gtk.Gtk3theme.Test/aarch64/3.22"},
+		{"flatpak", "install", "--user", "--noninteractive", "--reinstall", "-y", extensionRemote,
+			"runtime/org.gtk.Gtk3theme.Test/x86_64/3.22"},
+	}
+	if got := r.commands("install"); !reflect.DeepEqual(got, wantInstall) {
+		t.Errorf("install calls:\n%q\nwant\n%q", got, wantInstall)
+	}
+
+	// nothing changed: no export, no install
+	r.calls = nil
+	if err := InstallThemeExtension("Test", r); err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []string{"build-export", "remote-add", "install"} {
+		if got := r.commands(sub); len(got) > 0 {
+			t.Errorf("unchanged theme: unexpected %s calls %q", sub, got)
+		}
+	}
+
+	// a changed theme is exported and installed again
+	css := filepath.Join(dataHome, "themes", "Test", "gtk-3.20", "gtk.css")
+	if err := os.WriteFile(css, []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r.calls = nil
+	if err := InstallThemeExtension("Test", r); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.commands("build-export"); !reflect.DeepEqual(got, wantExport) {
+		t.Errorf("changed theme: build-export calls:\n%q\nwant\n%q", got, wantExport)
+	}
+	if got := r.commands("install"); !reflect.DeepEqual(got, wantInstall) {
+		t.Errorf("changed theme: install calls:\n%q\nwant\n%q", got, wantInstall)
+	}
+}
+
+func TestThemeDirForVersion(t *testing.T) {
+	themePath := t.TempDir()
+	for _, dir := range []string{"gtk-3.0", "gtk-3.20", "gtk-3.24"} {
+		if err := os.MkdirAll(filepath.Join(themePath, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[int]string{22: "gtk-3.20", 24: "gtk-3.24", 30: "gtk-3.24", 18: "gtk-3.0"}
+	for minor, want := range tests {
+		got, err := themeDirForVersion(themePath, minor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != filepath.Join(themePath, want) {
+			t.Errorf("themeDirForVersion(3.%d) = %s, want %s", minor, got, want)
+		}
+	}
+}
+
+func TestParseExtensionVersions(t *testing.T) {
+	tests := map[string][]string{
+		platformMetadata: {"3.22"},
+		"[Extension org.gtk.Gtk3theme]\nversions=3.22;3.20;\n":   {"3.22", "3.20"},
+		"[Extension org.freedesktop.Platform.GL]\nversion=1.4\n": nil,
+	}
+	for metadata, want := range tests {
+		if got := parseExtensionVersions(metadata); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseExtensionVersions(%q) = %q, want %q", metadata, got, want)
+		}
+	}
+}
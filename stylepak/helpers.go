@@ -59,10 +59,11 @@ func findThemePath(theme, dataHome, home string) (string, error) {
 	return "", errors.New("theme directory not found in known locations")
 }
 
-func detectGTKVersion(themePath string) (string, error) {
+// detectGTKVersions returns minor versions of gtk-3.x directories of the theme, newest first
+func detectGTKVersions(themePath string) ([]int, error) {
 	entries, err := os.ReadDir(themePath)
 	if err != nil {
-		return "", fmt.Errorf("read theme dir %q: %w", themePath, err)
+		return nil, fmt.Errorf("read theme dir %q: %w", themePath, err)
 	}
 
 	re := regexp.MustCompile(`gtk-3\.(\d+)$`)
@@ -77,11 +78,28 @@ func detectGTKVersion(themePath string) (string, error) {
 	}
 
 	if len(versions) == 0 {
-		return "", errors.New("no GTK 3.x directories found")
+		return nil, errors.New("no GTK 3.x directories found")
 	}
 
 	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
-	return fmt.Sprintf("%d", versions[0]), nil
+	return versions, nil
+}
+
+// themeDirForVersion returns the gtk-3.x directory of the theme GTK 3.<minor> loads: the newest one not
+// newer than the GTK version, or the oldest one if all of them are newer
+func themeDirForVersion(themePath string, minor int) (string, error) {
+	versions, err := detectGTKVersions(themePath)
+	if err != nil {
+		return "", err
+	}
+	v := versions[len(versions)-1]
+	for _, candidate := range versions {
+		if candidate <= minor {
+			v = candidate
+			break
+		}
+	}
+	return filepath.Join(themePath, fmt.Sprintf("gtk-3.%d", v)), nil
 }
 
 func getFlatpakArchitectures(r Runner) ([]string, error) {
@@ -132,6 +150,12 @@ func copyDir(src, dst string) error {
 			if err != nil {
 				return fmt.Errorf("read symlink %q: %w", path, err)
 			}
+			// replace links left by a previous copy
+			if _, err := os.Lstat(target); err == nil {
+				if err := os.Remove(target); err != nil {
+					return fmt.Errorf("remove existing %q: %w", target, err)
+				}
+			}
 			if err := os.Symlink(link, target); err != nil {
 				return fmt.Errorf("create symlink %q: %w", target, err)
 			}
@@ -142,6 +166,12 @@ func copyDir(src, dst string) error {
 			}
 
 		default:
+			// don't write through a link left by a previous copy
+			if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				if err := os.Remove(target); err != nil {
+					return fmt.Errorf("remove existing %q: %w", target, err)
+				}
+			}
 			if err := copyFile(path, target, info.Mode()); err != nil {
 				return err
 			}
@@ -0,0 +1,185 @@
+package stylepak
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// hostIconsDir is bound to /run/host/share/icons in the sandbox by flatpak, themes there need no copy
+const hostIconsDir = "/usr/share/icons"
+
+// iconThemeDirs returns locations to look up icon and cursor themes in, user directories first
+func iconThemeDirs(home string) []string {
+	dirs := []string{filepath.Join(home, ".icons")}
+	for _, d := range strings.Split(getenvDefault("XDG_DATA_DIRS", "/usr/local/share:/usr/share"), ":") {
+		if d != "" {
+			dirs = append(dirs, filepath.Join(d, "icons"))
+		}
+	}
+	return dirs
+}
+
+// findThemeDir returns the first theme directory found in the given locations
+func findThemeDir(theme string, dirs []string) (string, error) {
+	for _, base := range dirs {
+		full := filepath.Join(base, theme)
+		if fi, err := os.Stat(full); err == nil && fi.IsDir() {
+			return full, nil
+		}
+	}
+	return "", fmt.Errorf("theme %q not found in known locations", theme)
+}
+
+// iconThemeInherits returns parent themes listed in the Inherits= key of index.theme
+func iconThemeInherits(themePath string) []string {
+	data, err := os.ReadFile(filepath.Join(themePath, "index.theme"))
+	if err != nil {
+		return nil
+	}
+
+	var parents []string
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "Icon Theme" || strings.TrimSpace(key) != "Inherits" {
+			continue
+		}
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				parents = append(parents, p)
+			}
+		}
+	}
+	return parents
+}
+
+// resolveIconThemes returns the themes, and all themes they inherit from, with their paths.
+// hicolor is skipped, as every runtime ships it. Themes not found are logged and skipped.
+func resolveIconThemes(themes []string, dirs []string) ([]string, map[string]string) {
+	var names []string
+	paths := make(map[string]string)
+	visited := make(map[string]bool)
+
+	queue := append([]string{}, themes...)
+	for len(queue) > 0 {
+		theme := queue[0]
+		queue = queue[1:]
+		if theme == "" || theme == "hicolor" || visited[theme] {
+			continue
+		}
+		visited[theme] = true
+
+		if err := validateTheme(theme); err != nil {
+			log.Warn(err)
+			continue
+		}
+		path, err := findThemeDir(theme, dirs)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		names = append(names, theme)
+		paths[theme] = path
+		queue = append(queue, iconThemeInherits(path)...)
+	}
+	return names, paths
+}
+
+// InstallUserIconThemes copies the icon and cursor themes, and themes they inherit from, to
+// $XDG_DATA_HOME/icons, and grants flatpak apps read-only access to it. Themes in /usr/share/icons,
+// which flatpak apps see already, and themes in $XDG_DATA_HOME/icons are not copied. Empty theme
+// names are replaced with current gsettings values. Copies are marked like themes from InstallUserTheme:
+// directories without the marker belong to the user and are left untouched.
+func InstallUserIconThemes(iconTheme, cursorTheme string, runner Runner) error {
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("resolve home directory: %w", err)
+	}
+
+	dataHome := getenvDefault("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	iconsDir := filepath.Join(dataHome, "icons")
+
+	for key, theme := range map[string]*string{"icon-theme": &iconTheme, "cursor-theme": &cursorTheme} {
+		if *theme != "" {
+			continue
+		}
+		out, err := runner.Output("gsettings", "get", "org.gnome.desktop.interface", key)
+		if err != nil {
+			return fmt.Errorf("failed to get current %s: %w", key, err)
+		}
+		*theme = strings.Trim(string(out), "'\n ")
+	}
+
+	// the managed area comes last, so that existing copies are refreshed from their sources
+	names, paths := resolveIconThemes([]string{iconTheme, cursorTheme}, append(iconThemeDirs(home), iconsDir))
+	if len(names) == 0 {
+		return fmt.Errorf("icon theme %q and cursor theme %q not found", iconTheme, cursorTheme)
+	}
+	log.Info("Icon and cursor themes to install:", strings.Join(names, ", "))
+
+	if err := os.MkdirAll(iconsDir, 0o755); err != nil {
+		return fmt.Errorf("create dir %q: %w", iconsDir, err)
+	}
+
+	var keep []string
+	for _, name := range names {
+		src := paths[name]
+		dst := filepath.Join(iconsDir, name)
+		if src == dst {
+			log.Infof("%s is in %s already", name, iconsDir)
+			keep = append(keep, name)
+			continue
+		}
+		if filepath.Dir(src) == hostIconsDir {
+			log.Infof("%s is in %s, visible to flatpak apps without a copy", name, hostIconsDir)
+			continue
+		}
+		keep = append(keep, name)
+
+		action, err := determineInstallAction(dst)
+		if err != nil {
+			return err
+		}
+
+		switch action {
+		case themeActionFreshInstall:
+			log.Infof("Installing icon theme %s to %s", name, dst)
+			if err := syncTheme(src, dst, includeAll); err != nil {
+				return err
+			}
+		case themeActionUpdate:
+			if err := syncTheme(src, dst, includeAll); err != nil {
+				return err
+			}
+		case themeActionSkip:
+			log.Infof("%s already exists and was not created by nwg-look, skipping copy", dst)
+		}
+	}
+
+	if err := removeStaleThemes(iconsDir, keep...); err != nil {
+		return fmt.Errorf("remove stale icon themes: %w", err)
+	}
+
+	if err := runner.Run("flatpak", "override", "--user",
+		"--filesystem=xdg-data/icons:ro",
+	); err != nil {
+		return fmt.Errorf("flatpak override xdg-data/icons: %w", err)
+	}
+	log.Info("Configured flatpak to access ", iconsDir)
+
+	log.Infof("Successfully installed icon themes: %s", strings.Join(names, ", "))
+	return nil
+}
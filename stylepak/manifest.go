@@ -0,0 +1,433 @@
+package stylepak
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	manifestDir  = "dir"
+	manifestLink = "link:"
+	manifestTree = "tree:" // copy of a directory outside the theme, see resolveLink
+)
+
+// manifest is stored in the marker file of managed theme copies
+type manifest struct {
+	Source string            `json:"source"`
+	Stamp  string            `json:"stamp,omitempty"` // sourceStamp of the source when synced
+	Files  map[string]string `json:"files"`           // relative path to "dir", "link:<target>", "tree:<hash>" or sha256 of the content
+}
+
+// readManifest returns the manifest of the managed copy, and false if the marker is missing or was
+// written by an older version, as plain text
+func readManifest(dir string) (manifest, bool) {
+	m := manifest{Files: make(map[string]string)}
+	data, err := os.ReadFile(filepath.Join(dir, nwgLookMarker))
+	if err != nil {
+		return m, false
+	}
+	if err := json.Unmarshal(data, &m); err != nil || m.Files == nil {
+		return manifest{Files: make(map[string]string)}, false
+	}
+	return m, true
+}
+
+func writeManifest(dir string, m manifest) error {
+	data, err := json.MarshalIndent(m, "", " ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, nwgLookMarker), data, 0o644); err != nil {
+		return fmt.Errorf("write nwg-look marker: %w", err)
+	}
+	return nil
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash file %q: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sourceStamp sums up paths, sizes, modes and modification times of the entries of src accepted by include,
+// and of the targets of its symlinks, to tell if a copy is up to date without reading the files
+func sourceStamp(src string, include func(name string) bool) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk path %q: %w", path, err)
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return fmt.Errorf("compute relative path: %w", err)
+		}
+		top, _, _ := strings.Cut(rel, string(filepath.Separator))
+		if rel != "." && (top == nwgLookMarker || !include(top)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%v\x00%d", rel, info.Size(), info.Mode(), info.ModTime().UnixNano())
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, _ := os.Readlink(path)
+			fmt.Fprintf(h, "\x00%s", link)
+			if target, err := os.Stat(path); err == nil {
+				fmt.Fprintf(h, "\x00%d\x00%d", target.Size(), target.ModTime().UnixNano())
+			}
+		}
+		fmt.Fprintln(h)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolveLink turns the absolute link target of path, which would dangle in the sandbox, into a relative one
+// if it points into src. Links pointing elsewhere are resolved: it returns an empty link, and the info of
+// what path points to, or an error if the link is dangling.
+func resolveLink(src, path, link string) (string, os.FileInfo, error) {
+	roots := []string{src}
+	if real, err := filepath.EvalSymlinks(src); err == nil && real != src {
+		roots = append(roots, real)
+	}
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, link); err == nil && !strings.HasPrefix(rel, "..") {
+			relLink, err := filepath.Rel(filepath.Dir(path), filepath.Join(src, rel))
+			if err != nil {
+				return "", nil, fmt.Errorf("compute relative link: %w", err)
+			}
+			return relLink, nil, nil
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("resolve symlink %q: %w", path, err)
+	}
+	return "", info, nil
+}
+
+// syncDir mirrors top-level entries of src accepted by include into dst. Only entries that differ from the
+// old manifest are written, and entries missing from src are removed. It returns the new manifest, and
+// whether anything has changed.
+func syncDir(src, dst string, include func(name string) bool, old manifest) (manifest, bool, error) {
+	m := manifest{Source: src, Files: make(map[string]string)}
+	changed := old.Source != src
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk path %q: %w", path, err)
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return fmt.Errorf("compute relative path: %w", err)
+		}
+		if rel == "." {
+			return nil
+		}
+		top, _, _ := strings.Cut(rel, string(filepath.Separator))
+		if top == nwgLookMarker || !include(top) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(dst, rel)
+		existing, statErr := os.Lstat(target)
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return fmt.Errorf("read symlink %q: %w", path, err)
+			}
+			if filepath.IsAbs(link) {
+				relLink, resolved, err := resolveLink(src, path, link)
+				if err != nil {
+					log.Warnf("Skipping dangling link: %s", err)
+					return nil
+				}
+				if relLink != "" {
+					link = relLink
+				} else {
+					info = resolved
+				}
+			}
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			m.Files[rel] = manifestLink + link
+			if old.Files[rel] == m.Files[rel] && statErr == nil && existing.Mode()&os.ModeSymlink != 0 {
+				if current, err := os.Readlink(target); err == nil && current == link {
+					return nil
+				}
+			}
+			changed = true
+			if err := os.RemoveAll(target); err != nil {
+				return fmt.Errorf("remove %q: %w", target, err)
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return fmt.Errorf("create symlink %q: %w", target, err)
+			}
+
+		case info.IsDir() && link != "":
+			// a link to a directory outside the theme, walk doesn't follow it
+			dir, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return fmt.Errorf("resolve symlink %q: %w", path, err)
+			}
+			hash, err := treeHash(dir)
+			if err != nil {
+				return err
+			}
+			m.Files[rel] = manifestTree + hash
+			if old.Files[rel] == m.Files[rel] && statErr == nil && existing.IsDir() {
+				return nil
+			}
+			changed = true
+			if err := os.RemoveAll(target); err != nil {
+				return fmt.Errorf("remove %q: %w", target, err)
+			}
+			if err := copyDir(dir, target); err != nil {
+				return err
+			}
+
+		case info.IsDir():
+			m.Files[rel] = manifestDir
+			if statErr == nil && existing.IsDir() {
+				return nil
+			}
+			changed = true
+			if err := os.RemoveAll(target); err != nil {
+				return fmt.Errorf("remove %q: %w", target, err)
+			}
+			if err := os.MkdirAll(target, info.Mode()); err != nil {
+				return fmt.Errorf("create dir %q: %w", target, err)
+			}
+
+		default:
+			hash, err := fileHash(path)
+			if err != nil {
+				return err
+			}
+			m.Files[rel] = hash
+			if old.Files[rel] == hash && statErr == nil && existing.Mode().IsRegular() {
+				return nil
+			}
+			changed = true
+			if err := os.RemoveAll(target); err != nil {
+				return fmt.Errorf("remove %q: %w", target, err)
+			}
+			if err := copyFile(path, target, info.Mode()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return m, changed, err
+	}
+
+	// mirror deletions, deeper paths first
+	var gone []string
+	for rel := range old.Files {
+		if _, ok := m.Files[rel]; !ok {
+			gone = append(gone, rel)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(gone)))
+	for _, rel := range gone {
+		changed = true
+		if err := os.RemoveAll(filepath.Join(dst, rel)); err != nil {
+			return m, changed, fmt.Errorf("remove %q: %w", rel, err)
+		}
+	}
+
+	return m, changed, nil
+}
+
+// syncTheme updates the managed copy in dst from src, and records the manifest in its marker. Nothing is
+// read or written if the source stamp hasn't changed since the last sync; ReinstallManagedTheme repairs
+// copies changed by hand. Copies with markers of older versions are rebuilt from scratch.
+func syncTheme(src, dst string, include func(name string) bool) error {
+	stamp, err := sourceStamp(src, include)
+	if err != nil {
+		return err
+	}
+	old, ok := readManifest(dst)
+	if ok && old.Source == src && old.Stamp == stamp {
+		log.Infof("%s is up to date", dst)
+		return nil
+	}
+	if !ok && isDir(dst) {
+		log.Infof("No manifest in %s, copying from scratch", dst)
+		if err := clean(dst); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return fmt.Errorf("create dir %q: %w", dst, err)
+	}
+
+	m, changed, err := syncDir(src, dst, include, old)
+	if err != nil {
+		return err
+	}
+	m.Stamp = stamp
+	if err := writeManifest(dst, m); err != nil {
+		return err
+	}
+	if changed || !ok {
+		log.Infof("Synced %s from %s", dst, src)
+	} else {
+		log.Infof("%s is up to date", dst)
+	}
+	return nil
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// includeGtkThemeEntry selects what InstallUserTheme copies: gtk-* dirs and index.theme
+func includeGtkThemeEntry(name string) bool {
+	return strings.HasPrefix(name, "gtk-") || name == "index.theme"
+}
+
+func includeAll(string) bool {
+	return true
+}
+
+// kinds of managed themes
+const (
+	ManagedGtkTheme  = "gtk"
+	ManagedIconTheme = "icons"
+)
+
+// ManagedTheme is a theme copy made by InstallUserTheme or InstallUserIconThemes
+type ManagedTheme struct {
+	Kind   string
+	Name   string
+	Path   string
+	Source string // empty for copies made by older versions
+}
+
+// managedThemeDirs returns directories managed copies are made in, by kind
+func managedThemeDirs() (map[string]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("resolve home directory: %w", err)
+	}
+	dataHome := getenvDefault("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	return map[string]string{
+		ManagedGtkTheme:  filepath.Join(home, ".themes"),
+		ManagedIconTheme: filepath.Join(dataHome, "icons"),
+	}, nil
+}
+
+// ListManagedThemes returns theme copies with the nwg-look marker
+func ListManagedThemes() ([]ManagedTheme, error) {
+	dirs, err := managedThemeDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	var themes []ManagedTheme
+	for _, kind := range []string{ManagedGtkTheme, ManagedIconTheme} {
+		entries, err := os.ReadDir(dirs[kind])
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read themes dir %q: %w", dirs[kind], err)
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dirs[kind], entry.Name())
+			if !entry.IsDir() {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(path, nwgLookMarker)); err != nil {
+				continue
+			}
+			m, _ := readManifest(path)
+			themes = append(themes, ManagedTheme{Kind: kind, Name: entry.Name(), Path: path, Source: m.Source})
+		}
+	}
+	return themes, nil
+}
+
+// RemoveManagedTheme removes the theme copy
+func RemoveManagedTheme(t ManagedTheme) error {
+	if _, err := os.Stat(filepath.Join(t.Path, nwgLookMarker)); err != nil {
+		return fmt.Errorf("%s is not managed by nwg-look", t.Path)
+	}
+	log.Infof("Removing managed theme: %s", t.Path)
+	return clean(t.Path)
+}
+
+// RemoveManagedThemeAccess takes back the global filesystem overrides InstallUserTheme and
+// InstallUserIconThemes grant flatpak apps
+func RemoveManagedThemeAccess() error {
+	dirs, err := managedThemeDirs()
+	if err != nil {
+		return err
+	}
+	return RemoveOverrideFilesystems("", dirs[ManagedGtkTheme]+":ro", "~/.themes:ro", "xdg-data/icons:ro")
+}
+
+// ReinstallManagedTheme copies the theme again from its source, from scratch
+func ReinstallManagedTheme(t ManagedTheme) error {
+	src := t.Source
+	if src == "" || !isDir(src) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("resolve home directory: %w", err)
+		}
+		dataHome := getenvDefault("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+
+		var dirs []string
+		if t.Kind == ManagedGtkTheme {
+			dirs = []string{filepath.Join(dataHome, "themes"), "/usr/share/themes"}
+		} else {
+			dirs = iconThemeDirs(home)
+		}
+		if src, err = findThemeDir(t.Name, dirs); err != nil {
+			return err
+		}
+	}
+	if src == t.Path {
+		return errors.New("managed theme is its own source")
+	}
+
+	include := includeAll
+	if t.Kind == ManagedGtkTheme {
+		include = includeGtkThemeEntry
+	}
+	if err := RemoveManagedTheme(t); err != nil {
+		return err
+	}
+	return syncTheme(src, t.Path, include)
+}
@@ -0,0 +1,225 @@
+package stylepak
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setUpSyncTest returns a source theme with a gtk-3.0/gtk.css, and a destination for its copy
+func setUpSyncTest(t *testing.T) (string, string) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src", "Test")
+	writeTestFile(t, filepath.Join(src, "gtk-3.0", "gtk.css"), "css")
+	writeTestFile(t, filepath.Join(src, "index.theme"), "[Desktop Entry]")
+	writeTestFile(t, filepath.Join(src, "README"), "not copied")
+	return src, filepath.Join(root, "dst", "Test")
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// touch moves the modification time of path forward, so that the source stamp changes even on file
+// systems with coarse timestamps
+func touch(t *testing.T, path string) {
+	t.Helper()
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSyncThemeSkipsUnchangedSource(t *testing.T) {
+	src, dst := setUpSyncTest(t)
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(dst, "gtk-3.0", "gtk.css")); got != "css" {
+		t.Errorf("copied gtk.css = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "README")); !os.IsNotExist(err) {
+		t.Errorf("excluded README copied: %v", err)
+	}
+
+	// the copy is not looked at when the source is unchanged
+	css := filepath.Join(dst, "gtk-3.0", "gtk.css")
+	writeTestFile(t, css, "edited")
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, css); got != "edited" {
+		t.Errorf("unchanged source: gtk.css rewritten to %q", got)
+	}
+
+	// nor are unchanged files, when something else changes
+	writeTestFile(t, filepath.Join(src, "index.theme"), "[Desktop Entry]\nName=Test")
+	touch(t, filepath.Join(src, "index.theme"))
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, css); got != "edited" {
+		t.Errorf("unchanged file: gtk.css rewritten to %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(dst, "index.theme")); got != "[Desktop Entry]\nName=Test" {
+		t.Errorf("changed index.theme = %q", got)
+	}
+}
+
+func TestSyncThemeCopiesChangedFile(t *testing.T) {
+	src, dst := setUpSyncTest(t)
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+
+	css := filepath.Join(src, "gtk-3.0", "gtk.css")
+	writeTestFile(t, css, "changed")
+	touch(t, css)
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(dst, "gtk-3.0", "gtk.css")); got != "changed" {
+		t.Errorf("changed gtk.css = %q, want %q", got, "changed")
+	}
+}
+
+func TestSyncThemeMirrorsDeletions(t *testing.T) {
+	src, dst := setUpSyncTest(t)
+	writeTestFile(t, filepath.Join(src, "gtk-3.20", "gtk.css"), "css")
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(src, "gtk-3.20")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(src, "index.theme")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"gtk-3.20", "index.theme"} {
+		if _, err := os.Lstat(filepath.Join(dst, rel)); !os.IsNotExist(err) {
+			t.Errorf("deleted %s still in the copy: %v", rel, err)
+		}
+	}
+	m, ok := readManifest(dst)
+	if !ok {
+		t.Fatal("no manifest")
+	}
+	if _, ok := m.Files["gtk-3.20/gtk.css"]; ok {
+		t.Error("deleted gtk-3.20/gtk.css still in the manifest")
+	}
+	if got := readTestFile(t, filepath.Join(dst, "gtk-3.0", "gtk.css")); got != "css" {
+		t.Errorf("kept gtk.css = %q", got)
+	}
+}
+
+func TestSyncDirReplacesSymlink(t *testing.T) {
+	src, dst := setUpSyncTest(t)
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+	old, ok := readManifest(dst)
+	if !ok {
+		t.Fatal("no manifest")
+	}
+
+	// a link where the manifest has a file, e.g. from an older version that linked instead of copying
+	css := filepath.Join(dst, "gtk-3.0", "gtk.css")
+	if err := os.Remove(css); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(src, "gtk-3.0", "gtk.css"), css); err != nil {
+		t.Fatal(err)
+	}
+
+	_, changed, err := syncDir(src, dst, includeGtkThemeEntry, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("syncDir reported no change")
+	}
+	info, err := os.Lstat(css)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Errorf("gtk.css is %v, want a regular file", info.Mode())
+	}
+	if got := readTestFile(t, css); got != "css" {
+		t.Errorf("gtk.css = %q", got)
+	}
+
+	// nothing to do the second time
+	if _, changed, err := syncDir(src, dst, includeGtkThemeEntry, old); err != nil || changed {
+		t.Errorf("second syncDir: changed %v, error %v", changed, err)
+	}
+}
+
+func TestSyncDirRelativizesLinks(t *testing.T) {
+	src, dst := setUpSyncTest(t)
+	if err := os.Symlink(filepath.Join(src, "gtk-3.0"), filepath.Join(src, "gtk-4.0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+		t.Fatal(err)
+	}
+	link, err := os.Readlink(filepath.Join(dst, "gtk-4.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "gtk-3.0" {
+		t.Errorf("gtk-4.0 links to %q, want %q", link, "gtk-3.0")
+	}
+}
+
+func TestSyncThemeRebuildsWithoutManifest(t *testing.T) {
+	markers := map[string]string{
+		"missing": "",
+		"old":     "managed by nwg-look\n",
+	}
+	for name, marker := range markers {
+		t.Run(name, func(t *testing.T) {
+			src, dst := setUpSyncTest(t)
+			stray := filepath.Join(dst, "gtk-2.0", "gtkrc")
+			writeTestFile(t, stray, "stray")
+			writeTestFile(t, filepath.Join(dst, "gtk-3.0", "gtk.css"), "stale")
+			if marker != "" {
+				writeTestFile(t, filepath.Join(dst, nwgLookMarker), marker)
+			}
+
+			if err := syncTheme(src, dst, includeGtkThemeEntry); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(stray); !os.IsNotExist(err) {
+				t.Errorf("stray file kept: %v", err)
+			}
+			if got := readTestFile(t, filepath.Join(dst, "gtk-3.0", "gtk.css")); got != "css" {
+				t.Errorf("gtk.css = %q, want %q", got, "css")
+			}
+			m, ok := readManifest(dst)
+			if !ok || m.Source != src || m.Stamp == "" {
+				t.Errorf("manifest = %+v, valid %v", m, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package stylepak
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Override is the parsed output of `flatpak override --user --show`, for all apps or a single one
+type Override struct {
+	App         string // empty for global overrides
+	Filesystems []string
+	Env         map[string]string
+}
+
+func parseOverride(app, text string) Override {
+	o := Override{App: app, Env: make(map[string]string)}
+	group := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = line[1 : len(line)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch {
+		case group == "Context" && key == "filesystems":
+			for _, fs := range strings.Split(value, ";") {
+				if fs != "" {
+					o.Filesystems = append(o.Filesystems, fs)
+				}
+			}
+		case group == "Environment":
+			o.Env[key] = value
+		}
+	}
+	return o
+}
+
+// ShowOverride returns user overrides of the app, or global ones if app is empty
+func ShowOverride(r Runner, app string) (Override, error) {
+	if r == nil {
+		r = ExecRunner{}
+	}
+	args := []string{"override", "--user", "--show"}
+	if app != "" {
+		args = append(args, app)
+	}
+	out, err := r.Output("flatpak", args...)
+	if err != nil {
+		return Override{}, err
+	}
+	return parseOverride(app, string(out)), nil
+}
+
+func overridesDir() (string, error) {
+	if dir := os.Getenv("FLATPAK_USER_DIR"); dir != "" {
+		return filepath.Join(dir, "overrides"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory: %w", err)
+	}
+	dataHome := getenvDefault("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	return filepath.Join(dataHome, "flatpak", "overrides"), nil
+}
+
+// OverrideApps returns IDs of apps with user overrides. Flatpak has no command to list them,
+// so the overrides directory is read.
+func OverrideApps() ([]string, error) {
+	dir, err := overridesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read overrides dir %q: %w", dir, err)
+	}
+
+	var apps []string
+	for _, e := range entries {
+		if !e.IsDir() && e.Name() != "global" {
+			apps = append(apps, e.Name())
+		}
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
+
+// RunOverride runs `flatpak override --user` with the given arguments, for the app or for all apps if app is empty
+func RunOverride(r Runner, app string, args ...string) error {
+	if r == nil {
+		r = ExecRunner{}
+	}
+	args = append([]string{"override", "--user"}, args...)
+	if app != "" {
+		args = append(args, app)
+	}
+	return r.Run("flatpak", args...)
+}
+
+// SetOverrideEnv sets an environment variable for the app, or for all apps if app is empty
+func SetOverrideEnv(r Runner, app, key, value string) error {
+	return RunOverride(r, app, fmt.Sprintf("--env=%s=%s", key, value))
+}
+
+// UnsetOverrideEnv removes an environment variable override of the app, or the global one
+func UnsetOverrideEnv(r Runner, app, key string) error {
+	return RunOverride(r, app, "--unset-env="+key)
+}
+
+// AddOverrideFilesystem grants the app, or all apps, access to the path
+func AddOverrideFilesystem(r Runner, app, path string) error {
+	return RunOverride(r, app, "--filesystem="+path)
+}
+
+// RemoveOverrideFilesystems takes filesystem overrides of the app, or global ones if app is empty, back.
+// `flatpak override --nofilesystem` adds a negated entry instead, which would also hide the path from
+// global overrides, so the overrides file is edited.
+func RemoveOverrideFilesystems(app string, filesystems ...string) error {
+	if len(filesystems) == 0 {
+		return nil
+	}
+	dir, err := overridesDir()
+	if err != nil {
+		return err
+	}
+	name := app
+	if name == "" {
+		name = "global"
+	}
+	path := filepath.Join(dir, name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read override %q: %w", path, err)
+	}
+
+	var lines []string
+	group := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			group = trimmed[1 : len(trimmed)-1]
+		}
+		key, value, ok := strings.Cut(trimmed, "=")
+		if group != "Context" || !ok || key != "filesystems" {
+			lines = append(lines, line)
+			continue
+		}
+		var kept []string
+		for _, fs := range strings.Split(value, ";") {
+			if fs != "" && !slices.Contains(filesystems, fs) {
+				kept = append(kept, fs)
+			}
+		}
+		if len(kept) > 0 {
+			lines = append(lines, "filesystems="+strings.Join(kept, ";")+";")
+		}
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		return fmt.Errorf("write override %q: %w", path, err)
+	}
+	return nil
+}
+
+// App is an installed flatpak application
+type App struct {
+	ID   string
+	Name string
+}
+
+// InstalledApps returns flatpak applications sorted by name. Apps installed both system-wide
+// and per user are listed once.
+func InstalledApps(r Runner) ([]App, error) {
+	if r == nil {
+		r = ExecRunner{}
+	}
+	out, err := r.Output("flatpak", "list", "--app", "--columns=application,name")
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []App
+	seen := make(map[string]bool)
+	for _, l := range strings.Split(string(out), "\n") {
+		id, name, _ := strings.Cut(strings.TrimSpace(l), "\t")
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = id
+		}
+		apps = append(apps, App{ID: id, Name: name})
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		return strings.ToLower(apps[i].Name) < strings.ToLower(apps[j].Name)
+	})
+	return apps, nil
+}
@@ -0,0 +1,113 @@
+package stylepak
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sampleOverride = `[Context]
+filesystems=xdg-data/themes:ro;!home;~/.themes:ro;
+
+[Environment]
+GTK_THEME=Adwaita:dark
+ICON_THEME=
+
+# comment=ignored
+[Session Bus Policy]
+org.freedesktop.Flatpak=talk
+`
+
+func TestShowOverride(t *testing.T) {
+	r := &fakeRunner{outputs: map[string]string{
+		"flatpak override --user --show":                 sampleOverride,
+		"flatpak override --user --show org.example.App": "[Context]\nfilesystems=!xdg-data/themes;\n",
+	}}
+
+	tests := []struct {
+		app  string
+		want Override
+	}{
+		{"", Override{
+			Filesystems: []string{"xdg-data/themes:ro", "!home", "~/.themes:ro"},
+			Env:         map[string]string{"GTK_THEME": "Adwaita:dark", "ICON_THEME": ""},
+		}},
+		{"org.example.App", Override{
+			App:         "org.example.App",
+			Filesystems: []string{"!xdg-data/themes"},
+			Env:         map[string]string{},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := ShowOverride(r, tt.app)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ShowOverride(%q) = %+v, want %+v", tt.app, got, tt.want)
+		}
+	}
+
+	if _, err := ShowOverride(r, "org.example.Missing"); err == nil {
+		t.Error("ShowOverride of a failing command: expected error")
+	}
+}
+
+func TestParseOverrideEmpty(t *testing.T) {
+	o := parseOverride("", "")
+	if o.Filesystems != nil || len(o.Env) != 0 {
+		t.Errorf("parseOverride of empty text = %+v", o)
+	}
+}
+
+func TestRemoveOverrideFilesystems(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("FLATPAK_USER_DIR", dir)
+	overrides := filepath.Join(dir, "overrides")
+	if err := os.MkdirAll(overrides, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	global := filepath.Join(overrides, "global")
+	if err := os.WriteFile(global, []byte(sampleOverride), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// negated entries are kept unless asked for, other groups are left alone
+	if err := RemoveOverrideFilesystems("", "xdg-data/themes:ro", "~/.themes:ro", "xdg-config/gtk-4.0:ro"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := parseOverride("", string(data))
+	if want := []string{"!home"}; !reflect.DeepEqual(o.Filesystems, want) {
+		t.Errorf("filesystems after removal = %q, want %q", o.Filesystems, want)
+	}
+	if o.Env["GTK_THEME"] != "Adwaita:dark" {
+		t.Errorf("environment changed: %q", o.Env)
+	}
+
+	// the line goes when nothing is left
+	if err := RemoveOverrideFilesystems("", "!home"); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[Context]\n\n[Environment]\nGTK_THEME=Adwaita:dark\nICON_THEME=\n\n" +
+		"# comment=ignored\n[Session Bus Policy]\norg.freedesktop.Flatpak=talk\n"
+	if string(data) != want {
+		t.Errorf("override file:\n%s\nwant\n%s", data, want)
+	}
+
+	// a missing override file is not an error and is not created
+	if err := RemoveOverrideFilesystems("org.example.App", "home"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(overrides, "org.example.App")); !os.IsNotExist(err) {
+		t.Errorf("override of org.example.App created: %v", err)
+	}
+}
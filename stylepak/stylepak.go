@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -70,7 +71,8 @@ func determineInstallAction(themeDir string) (themeInstallAction, error) {
 	return themeActionUpdate, nil
 }
 
-func removeStaleThemes(themesDir, currentTheme string) error {
+// removeStaleThemes removes nwg-look-managed themes other than the ones to keep
+func removeStaleThemes(themesDir string, keep ...string) error {
 	entries, err := os.ReadDir(themesDir)
 	if os.IsNotExist(err) {
 		return nil
@@ -80,7 +82,7 @@ func removeStaleThemes(themesDir, currentTheme string) error {
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == currentTheme {
+		if !entry.IsDir() || slices.Contains(keep, entry.Name()) {
 			continue
 		}
 		markerPath := filepath.Join(themesDir, entry.Name(), nwgLookMarker)
@@ -95,40 +97,18 @@ func removeStaleThemes(themesDir, currentTheme string) error {
 	return nil
 }
 
-func copyThemeFiles(src, dst string) error {
-	entries, err := os.ReadDir(src)
+// checkGtkTheme makes sure the theme has something for InstallUserTheme to copy
+func checkGtkTheme(themePath string) error {
+	entries, err := os.ReadDir(themePath)
 	if err != nil {
-		return fmt.Errorf("read theme dir %q: %w", src, err)
+		return fmt.Errorf("read theme dir %q: %w", themePath, err)
 	}
-
-	copied := 0
 	for _, entry := range entries {
-		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "gtk-") {
-			continue
-		}
-		entryDst := filepath.Join(dst, entry.Name())
-		if err := os.MkdirAll(entryDst, 0o755); err != nil {
-			return fmt.Errorf("create dir %q: %w", entryDst, err)
-		}
-		if err := copyDir(filepath.Join(src, entry.Name()), entryDst); err != nil {
-			return fmt.Errorf("copy theme dir %q: %w", entry.Name(), err)
-		}
-		copied++
-		log.Info("Copied theme dir:", entry.Name())
-	}
-
-	if copied == 0 {
-		return fmt.Errorf("no gtk-* directories found in theme %q", src)
-	}
-
-	if data, err := os.ReadFile(filepath.Join(src, "index.theme")); err == nil {
-		if err := os.WriteFile(filepath.Join(dst, "index.theme"), data, 0o644); err != nil {
-			return fmt.Errorf("write index.theme: %w", err)
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "gtk-") {
+			return nil
 		}
-		log.Info("Copied index.theme")
 	}
-
-	return nil
+	return fmt.Errorf("no gtk-* directories found in theme %q", themePath)
 }
 
 func InstallUserTheme(theme string, runner Runner) error {
@@ -160,16 +140,25 @@ func InstallUserTheme(theme string, runner Runner) error {
 	userThemeDir := filepath.Join(themesDir, theme)
 	markerPath := filepath.Join(userThemeDir, nwgLookMarker)
 
-	// Find system theme path, explicitly excluding ~/.themes to avoid
-	// copying a theme into itself
+	// Find system theme path. A copy in ~/.themes shadows it, and must not be copied into itself.
 	themePath, err := findThemePath(theme, dataHome, home)
 	if err != nil {
 		return fmt.Errorf("locate theme %q: %w", theme, err)
 	}
 	if themePath == userThemeDir {
-		return fmt.Errorf("theme %q is already in ~/.themes and was not installed by nwg-look", theme)
+		if _, err := os.Stat(markerPath); err != nil {
+			return fmt.Errorf("theme %q is already in ~/.themes and was not installed by nwg-look", theme)
+		}
+		// the managed copy shadows its source
+		themePath, err = findThemeDir(theme, []string{filepath.Join(dataHome, "themes"), "/usr/share/themes"})
+		if err != nil {
+			return fmt.Errorf("locate source of managed theme %q: %w", theme, err)
+		}
 	}
 	log.Info("Found theme located at:", themePath)
+	if err := checkGtkTheme(themePath); err != nil {
+		return err
+	}
 
 	if err := removeStaleThemes(themesDir, theme); err != nil {
 		return fmt.Errorf("remove stale themes: %w", err)
@@ -183,18 +172,12 @@ func InstallUserTheme(theme string, runner Runner) error {
 	switch action {
 	case themeActionFreshInstall:
 		log.Infof("Installing theme to ~/.themes/%s", theme)
-		if err := os.MkdirAll(userThemeDir, 0o755); err != nil {
-			return fmt.Errorf("create ~/.themes/%s: %w", theme, err)
-		}
-		if err := copyThemeFiles(themePath, userThemeDir); err != nil {
+		if err := syncTheme(themePath, userThemeDir, includeGtkThemeEntry); err != nil {
 			return err
 		}
-		if err := os.WriteFile(markerPath, []byte("managed by nwg-look\n"), 0o644); err != nil {
-			return fmt.Errorf("write nwg-look marker: %w", err)
-		}
 	case themeActionUpdate:
 		log.Infof("Updating existing nwg-look-managed theme at ~/.themes/%s", theme)
-		if err := copyThemeFiles(themePath, userThemeDir); err != nil {
+		if err := syncTheme(themePath, userThemeDir, includeGtkThemeEntry); err != nil {
 			return err
 		}
 	case themeActionSkip:
@@ -0,0 +1,173 @@
+// theme list search, filter chips and type-ahead navigation
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// typed characters further apart start a new type-ahead search
+const typeAheadTimeout = time.Second
+
+// themeListFilter is the state of the filter bar above a theme list
+type themeListFilter struct {
+	query  string
+	user   bool
+	system bool
+	// GTK themes only: show themes that support the version
+	gtk2 bool
+	gtk3 bool
+	gtk4 bool
+}
+
+// filters by theme kind, kept while switching pages
+var themeListFilters = map[string]*themeListFilter{
+	themeKindGtk:    {user: true, system: true},
+	themeKindIcon:   {user: true, system: true},
+	themeKindCursor: {user: true, system: true},
+}
+
+// themeListEntry describes a row of a theme list, for filtering
+type themeListEntry struct {
+	name       string // display name
+	folderName string
+	comment    string
+	user       bool
+	support    gtkThemeSupport // GTK themes only
+}
+
+// newThemeListEntry describes the theme of the kind, with the comment from its index.theme if any
+func newThemeListEntry(kind, name, folderName string) themeListEntry {
+	e := themeListEntry{name: name, folderName: folderName, user: userThemePath(kind, folderName) != ""}
+	path := themeFolderPath(kind, folderName)
+	if path == "" {
+		return e
+	}
+	values, err := parseIndexTheme(filepath.Join(path, "index.theme"))
+	if err == nil {
+		e.comment = values["Icon Theme/Comment"]
+		if e.comment == "" {
+			e.comment = values["Desktop Entry/Comment"]
+		}
+	}
+	return e
+}
+
+// matches checks if the entry passes the location and GTK version filters, and the query
+func (f *themeListFilter) matches(e themeListEntry) bool {
+	if e.user && !f.user || !e.user && !f.system {
+		return false
+	}
+	if f.gtk2 && !e.support.gtk2 || f.gtk3 && !e.support.gtk3 || f.gtk4 && !e.support.gtk4 {
+		return false
+	}
+	return fuzzyMatch(f.query, e)
+}
+
+// fuzzyMatch checks if every word of the query matches the entry: letters in order in the display or folder
+// name, or a substring of the comment. Case is ignored.
+func fuzzyMatch(query string, e themeListEntry) bool {
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !isSubsequence(word, strings.ToLower(e.name)) && !isSubsequence(word, strings.ToLower(e.folderName)) &&
+			!strings.Contains(strings.ToLower(e.comment), word) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSubsequence checks if runes of sub appear in s in the same order, not necessarily adjacent
+func isSubsequence(sub, s string) bool {
+	runes := []rune(sub)
+	i := 0
+	for _, r := range s {
+		if i < len(runes) && r == runes[i] {
+			i++
+		}
+	}
+	return i == len(runes)
+}
+
+// setUpThemeListFilter filters rows of the list box by the filter of the kind, and adds type-ahead navigation.
+// Entries must be in the order of rows.
+func setUpThemeListFilter(listBox *gtk.ListBox, kind string, entries []themeListEntry) {
+	f := themeListFilters[kind]
+	listBox.SetFilterFunc(func(row *gtk.ListBoxRow) bool {
+		i := row.GetIndex()
+		return i < 0 || i >= len(entries) || f.matches(entries[i])
+	})
+
+	typed := ""
+	var lastKey time.Time
+	listBox.Connect("key-press-event", func(_ *gtk.ListBox, event *gdk.Event) bool {
+		key := gdk.EventKeyNewFromEvent(event)
+		if key.State()&uint(gdk.CONTROL_MASK|gdk.MOD1_MASK) != 0 {
+			return false
+		}
+		r := gdk.KeyvalToUnicode(key.KeyVal())
+		if time.Since(lastKey) > typeAheadTimeout {
+			typed = ""
+		}
+		// space activates the row, unless typing a name
+		if !unicode.IsPrint(r) || r == ' ' && typed == "" {
+			return false
+		}
+		lastKey = time.Now()
+		typed += string(unicode.ToLower(r))
+
+		for i, e := range entries {
+			if f.matches(e) && strings.HasPrefix(strings.ToLower(e.name), typed) {
+				row := listBox.GetRowAtIndex(i)
+				listBox.SelectRow(row)
+				row.GrabFocus()
+				break
+			}
+		}
+		return true
+	})
+}
+
+// setUpThemeFilterBar returns the search entry and filter chips for the list of themes of the kind
+func setUpThemeFilterBar(kind string) *gtk.Box {
+	f := themeListFilters[kind]
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetProperty("margin-start", 6)
+	box.SetProperty("margin-top", 6)
+
+	entry, _ := gtk.SearchEntryNew()
+	entry.SetText(f.query)
+	entry.SetPlaceholderText(voc["search-themes"])
+	entry.SetTooltipText(voc["search-themes-tooltip"])
+	entry.Connect("search-changed", func() {
+		f.query, _ = entry.GetText()
+		listBox.InvalidateFilter()
+	})
+	box.PackStart(entry, false, false, 0)
+
+	chips, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	addChip := func(label string, value *bool) {
+		btn, _ := gtk.ToggleButtonNewWithLabel(label)
+		btn.SetActive(*value)
+		btn.Connect("toggled", func() {
+			*value = btn.GetActive()
+			listBox.InvalidateFilter()
+		})
+		chips.PackStart(btn, false, false, 0)
+	}
+	addChip(voc["user"], &f.user)
+	addChip(voc["system"], &f.system)
+	if kind == themeKindGtk {
+		addChip("GTK2", &f.gtk2)
+		addChip("GTK3", &f.gtk3)
+		addChip("GTK4", &f.gtk4)
+	}
+	box.PackStart(chips, false, false, 0)
+
+	return box
+}
@@ -0,0 +1,383 @@
+// user theme management
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	themeKindGtk    = "gtk"
+	themeKindIcon   = "icon"
+	themeKindCursor = "cursor"
+)
+
+// userThemeDirs returns user-writable locations that may hold themes of the given kind
+func userThemeDirs(kind string) []string {
+	home := os.Getenv("HOME")
+	if kind == themeKindGtk {
+		return []string{filepath.Join(dataHome(), "themes"), filepath.Join(home, ".themes")}
+	}
+	return []string{filepath.Join(dataHome(), "icons"), filepath.Join(home, ".icons")}
+}
+
+// allThemeDirs returns all locations (user and system) that may hold themes of the given kind
+func allThemeDirs(kind string) []string {
+	subDir := "icons"
+	if kind == themeKindGtk {
+		subDir = "themes"
+	}
+	var dirs []string
+	for _, dir := range dataDirs {
+		if pathExists(filepath.Join(dir, subDir)) {
+			dirs = append(dirs, filepath.Join(dir, subDir))
+		}
+	}
+	for _, dir := range userThemeDirs(kind) {
+		if pathExists(dir) && !isIn(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// userThemePaths returns all user-writable copies of the theme folder
+func userThemePaths(kind, folderName string) []string {
+	var paths []string
+	if folderName == "" || strings.Contains(folderName, "/") || folderName == "." || folderName == ".." {
+		return paths
+	}
+	for _, dir := range userThemeDirs(kind) {
+		p := filepath.Join(dir, folderName)
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// userThemePath returns the first user-writable copy of the theme folder, or an empty string
+func userThemePath(kind, folderName string) string {
+	paths := userThemePaths(kind, folderName)
+	if len(paths) > 0 {
+		return paths[0]
+	}
+	return ""
+}
+
+// themeFolderPath returns the first copy of the theme folder found in allThemeDirs, or an empty string
+func themeFolderPath(kind, folderName string) string {
+	for _, dir := range allThemeDirs(kind) {
+		p := filepath.Join(dir, folderName)
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// parseIndexTheme returns index.theme values as map["Section/Key"]value
+func parseIndexTheme(path string) (map[string]string, error) {
+	lines, err := loadTextFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	section := ""
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok {
+			values[fmt.Sprintf("%s/%s", section, strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return values, nil
+}
+
+// themeInherits returns the names of themes the theme at the given path depends on
+func themeInherits(kind, path string) []string {
+	var parents []string
+	values, err := parseIndexTheme(filepath.Join(path, "index.theme"))
+	if err != nil {
+		return parents
+	}
+	if kind == themeKindGtk {
+		if v := values["X-GNOME-Metatheme/GtkTheme"]; v != "" {
+			parents = append(parents, v)
+		}
+		return parents
+	}
+	for _, p := range strings.Split(values["Icon Theme/Inherits"], ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			parents = append(parents, p)
+		}
+	}
+	return parents
+}
+
+// themeDependents returns the names of installed themes that inherit from the given theme folder
+func themeDependents(kind, folderName string) []string {
+	var dependents []string
+	for _, dir := range allThemeDirs(kind) {
+		files, err := listFiles(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if !f.IsDir() || f.Name() == folderName || isIn(dependents, f.Name()) {
+				continue
+			}
+			if isIn(themeInherits(kind, filepath.Join(dir, f.Name())), folderName) {
+				dependents = append(dependents, f.Name())
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// appliedThemes returns theme names currently set in gsettings for the given kind.
+// Icon and cursor themes share folders, so both are checked for either kind.
+func appliedThemes(kind string) []string {
+	var keys []string
+	if kind == themeKindGtk {
+		keys = []string{"gtk-theme"}
+	} else {
+		keys = []string{"icon-theme", "cursor-theme"}
+	}
+	var names []string
+	for _, key := range keys {
+		val, err := getGsettingsValue("org.gnome.desktop.interface", key)
+		if err != nil {
+			log.Warnf("Couldn't read %s: %s", key, err)
+			continue
+		}
+		names = append(names, val)
+	}
+	return names
+}
+
+// checkThemeRemovable returns an error if the theme must not be removed
+func checkThemeRemovable(kind, folderName string) error {
+	if len(userThemePaths(kind, folderName)) == 0 {
+		return fmt.Errorf("theme '%s' not found in user theme directories", folderName)
+	}
+	if isIn(appliedThemes(kind), folderName) {
+		return fmt.Errorf("theme '%s' is currently applied", folderName)
+	}
+	if dependents := themeDependents(kind, folderName); len(dependents) > 0 {
+		return fmt.Errorf("theme '%s' is inherited by: %s", folderName, strings.Join(dependents, ", "))
+	}
+	return nil
+}
+
+// removeUserTheme deletes all user-writable copies of the theme folder
+func removeUserTheme(kind, folderName string) error {
+	if err := checkThemeRemovable(kind, folderName); err != nil {
+		return err
+	}
+	for _, p := range userThemePaths(kind, folderName) {
+		log.Infof("Removing '%s'", p)
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("couldn't remove '%s': %s", p, err)
+		}
+	}
+	return nil
+}
+
+// themeDetails returns human-readable lines describing the theme at the given path
+func themeDetails(kind, path string) []string {
+	lines := []string{fmt.Sprintf("%s: %s", voc["path"], path)}
+	values, err := parseIndexTheme(filepath.Join(path, "index.theme"))
+	if err == nil {
+		section := "Icon Theme"
+		if kind == themeKindGtk {
+			section = "Desktop Entry"
+		}
+		if v := values[section+"/Name"]; v != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s", voc["name"], v))
+		}
+		if v := values[section+"/Comment"]; v != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s", voc["comment"], v))
+		}
+	}
+	if inherits := themeInherits(kind, path); len(inherits) > 0 {
+		lines = append(lines, fmt.Sprintf("%s: %s", voc["inherits"], strings.Join(inherits, ", ")))
+	}
+	if kind == themeKindGtk {
+		var subdirs []string
+		files, err := listFiles(path)
+		if err == nil {
+			for _, f := range files {
+				if f.IsDir() && strings.HasPrefix(f.Name(), "gtk-") {
+					subdirs = append(subdirs, f.Name())
+				}
+			}
+		}
+		sort.Strings(subdirs)
+		lines = append(lines, fmt.Sprintf("%s: %s", voc["contains"], strings.Join(subdirs, ", ")))
+	}
+	return lines
+}
+
+// uninstallTheme is the `nwg-look uninstall NAME` command
+func uninstallTheme(name string) int {
+	var kinds []string
+	if len(userThemePaths(themeKindGtk, name)) > 0 {
+		kinds = append(kinds, themeKindGtk)
+	}
+	if len(userThemePaths(themeKindIcon, name)) > 0 {
+		kinds = append(kinds, themeKindIcon)
+	}
+	if len(kinds) == 0 {
+		fmt.Printf("Theme '%s' not found in user theme directories\n", name)
+		return 1
+	}
+
+	for _, kind := range kinds {
+		if err := checkThemeRemovable(kind, name); err != nil {
+			fmt.Printf("Can't remove: %s\n", err)
+			return 1
+		}
+	}
+
+	for _, kind := range kinds {
+		for _, p := range userThemePaths(kind, name) {
+			fmt.Println(p)
+		}
+	}
+	fmt.Print("Remove the directories listed above? y/N ")
+	var input string
+	fmt.Scanln(&input)
+	if strings.ToUpper(input) != "Y" {
+		return 0
+	}
+
+	for _, kind := range kinds {
+		if err := removeUserTheme(kind, name); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// gtkThemeSupport describes which toolkits a GTK theme provides styles for
+type gtkThemeSupport struct {
+	gtk2 bool
+	gtk3 bool
+	gtk4 bool
+	dark bool // gtk-dark.css in gtk-3.0 or gtk-4.0
+}
+
+// themes compiled into GTK 3 and 4, with no need for theme files
+var builtinGtkThemes = map[string]bool{
+	"Adwaita":             true,
+	"Adwaita-dark":        true,
+	"HighContrast":        true,
+	"HighContrastInverse": true,
+}
+
+func getGtkThemeSupport(name, path string) gtkThemeSupport {
+	s := gtkThemeSupport{}
+	if builtinGtkThemes[name] {
+		s.gtk3, s.gtk4, s.dark = true, true, true
+	}
+	files, err := listFiles(path)
+	if err != nil {
+		return s
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		switch {
+		case f.Name() == "gtk-2.0":
+			s.gtk2 = true
+		case strings.HasPrefix(f.Name(), "gtk-3."):
+			s.gtk3 = true
+		case strings.HasPrefix(f.Name(), "gtk-4."):
+			s.gtk4 = true
+		default:
+			continue
+		}
+		if pathExists(filepath.Join(path, f.Name(), "gtk-dark.css")) {
+			s.dark = true
+		}
+	}
+	return s
+}
+
+// themeSupportSummary returns lines describing which toolkits will be themed with the given GTK theme,
+// and false if any of them falls back to the default look.
+func themeSupportSummary(name string) ([]string, bool) {
+	path := gtkThemePath(name)
+	if path == "" && !builtinGtkThemes[name] {
+		return []string{fmt.Sprintf("'%s': %s", name, voc["theme-not-found"])}, false
+	}
+	s := getGtkThemeSupport(name, path)
+	allThemed := true
+
+	line := func(toolkit string, themed bool, reason string) string {
+		if themed {
+			return fmt.Sprintf("%s: %s", toolkit, voc["themed"])
+		}
+		allThemed = false
+		return fmt.Sprintf("%s: %s (%s)", toolkit, voc["falls-back-to-default"], reason)
+	}
+
+	var lines []string
+	if !s.gtk2 {
+		lines = append(lines, line("GTK 2", false, voc["no-gtk-2-dir"]))
+	} else if !preferences.ExportGtkRc20 {
+		lines = append(lines, line("GTK 2", false, voc["gtkrc-export-disabled"]))
+	} else {
+		lines = append(lines, line("GTK 2", true, ""))
+	}
+
+	if !s.gtk3 {
+		lines = append(lines, line("GTK 3", false, voc["no-gtk-3-dir"]))
+	} else {
+		lines = append(lines, line("GTK 3", true, ""))
+	}
+
+	if !s.gtk4 {
+		lines = append(lines, line("GTK 4", false, voc["no-gtk-4-dir"]))
+	} else if !preferences.ExportGtk4Symlinks && !builtinGtkThemes[name] {
+		lines = append(lines, line("GTK 4", false, voc["gtk4-export-disabled"]))
+	} else {
+		lines = append(lines, line("GTK 4", true, ""))
+	}
+
+	if gsettings.colorScheme == "prefer-dark" && !s.dark {
+		lines = append(lines, line(voc["dark-variant"], false, voc["no-gtk-dark-css"]))
+	}
+
+	return lines, allThemed
+}
+
+// checkTheme is the `nwg-look check-theme NAME` command
+func checkTheme(name string) int {
+	if gtkThemePath(name) == "" && !builtinGtkThemes[name] {
+		fmt.Printf("Theme '%s' not found\n", name)
+		return 1
+	}
+	lines, _ := themeSupportSummary(name)
+	fmt.Println(name)
+	for _, l := range lines {
+		fmt.Printf("  %s\n", l)
+	}
+	return 0
+}
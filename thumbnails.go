@@ -0,0 +1,299 @@
+// theme thumbnails in list rows
+package main
+
+/*
+#cgo pkg-config: gtk+-3.0
+#include <stdlib.h>
+#include <gtk/gtk.h>
+*/
+import "C"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	thumbnailHeight   = 24
+	thumbnailIconSize = 16
+	thumbnailTimeout  = 5 * time.Second
+)
+
+// thumbnail widths by theme kind, so that labels of rows line up
+var thumbnailWidths = map[string]int{
+	themeKindGtk:    96,
+	themeKindIcon:   4*thumbnailIconSize + 3*4,
+	themeKindCursor: thumbnailHeight,
+}
+
+// icons shown in icon theme thumbnails
+var thumbnailIcons = []string{"folder", "user-home", "text-x-generic", "utilities-terminal"}
+
+// incremented whenever the theme list is replaced, to drop thumbnails still pending for the old one
+var thumbnailGeneration atomic.Int64
+
+// thumbnailJob makes the thumbnail of a theme, and shows it in the image of its row
+type thumbnailJob struct {
+	kind   string
+	source string // file the thumbnail is made of, see thumbnailSource
+	img    *gtk.Image
+}
+
+func cacheHome() string {
+	xdgCacheHome := os.Getenv("XDG_CACHE_HOME")
+	if xdgCacheHome != "" {
+		return xdgCacheHome
+	}
+	return filepath.Join(os.Getenv("HOME"), ".cache")
+}
+
+func thumbnailCacheDir() string {
+	return filepath.Join(cacheHome(), "nwg-look", "thumbnails")
+}
+
+// thumbnailSource returns the file thumbnails of the theme at the path are made of: gtk-3.0/gtk.css,
+// index.theme or the left_ptr cursor. It returns an empty string if there's none.
+func thumbnailSource(kind, path string) string {
+	var source string
+	switch kind {
+	case themeKindGtk:
+		source = filepath.Join(path, "gtk-3.0", "gtk.css")
+	case themeKindIcon:
+		source = filepath.Join(path, "index.theme")
+	case themeKindCursor:
+		source = filepath.Join(path, "cursors", "left_ptr")
+	}
+	if path == "" || !pathExists(source) {
+		return ""
+	}
+	return source
+}
+
+// thumbnailCachePath returns the cache file of the thumbnail, keyed by the source path and modification time.
+// Icon theme thumbnails are also keyed by iconDirsStamp, as icons may change while index.theme doesn't.
+func thumbnailCachePath(kind, source string) (string, error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s\n%s\n%d", kind, source, fi.ModTime().UnixNano())
+	if kind == themeKindIcon {
+		key += iconDirsStamp(filepath.Dir(source))
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(thumbnailCacheDir(), hex.EncodeToString(sum[:16])+".png"), nil
+}
+
+// iconDirsStamp returns modification times of the icon theme directory and the icon directories listed in its
+// index.theme. Adding, removing or replacing an icon file, as package updates do, changes the time of its
+// directory.
+func iconDirsStamp(path string) string {
+	dirs := []string{"."}
+	if values, err := parseIndexTheme(filepath.Join(path, "index.theme")); err == nil {
+		for _, key := range []string{"Icon Theme/Directories", "Icon Theme/ScaledDirectories"} {
+			for _, dir := range strings.Split(values[key], ",") {
+				if dir = strings.TrimSpace(dir); dir != "" && !isIn(dirs, dir) {
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+	}
+
+	var stamp strings.Builder
+	for _, dir := range dirs {
+		if fi, err := os.Stat(filepath.Join(path, dir)); err == nil {
+			fmt.Fprintf(&stamp, "\n%s %d", dir, fi.ModTime().UnixNano())
+		}
+	}
+	return stamp.String()
+}
+
+// newThumbnailImage returns an empty image of the thumbnail size of the kind
+func newThumbnailImage(kind string) *gtk.Image {
+	img, _ := gtk.ImageNew()
+	img.SetSizeRequest(thumbnailWidths[kind], thumbnailHeight)
+	img.SetProperty("margin-start", 6)
+	return img
+}
+
+// loadThumbnails makes missing thumbnails in the background, one at a time, and shows them as they get ready
+func loadThumbnails(jobs []thumbnailJob) {
+	generation := thumbnailGeneration.Add(1)
+	if err := os.MkdirAll(thumbnailCacheDir(), 0o755); err != nil {
+		log.Warnf("Couldn't create thumbnail cache: %s", err)
+		return
+	}
+
+	go func() {
+		for _, job := range jobs {
+			if thumbnailGeneration.Load() != generation {
+				return
+			}
+			cachePath, err := thumbnailCachePath(job.kind, job.source)
+			if err != nil {
+				log.Debugf("Couldn't stat '%s': %s", job.source, err)
+				continue
+			}
+			if !pathExists(cachePath) {
+				if err := makeThumbnail(job, cachePath); err != nil {
+					log.Debugf("Couldn't make thumbnail of '%s': %s", job.source, err)
+					continue
+				}
+			}
+
+			img, kind := job.img, job.kind
+			glib.IdleAdd(func() {
+				if thumbnailGeneration.Load() != generation {
+					return
+				}
+				pixbuf, err := gdk.PixbufNewFromFileAtScale(cachePath, thumbnailWidths[kind], thumbnailHeight, true)
+				if err != nil {
+					log.Debugf("Couldn't load thumbnail '%s': %s", cachePath, err)
+					return
+				}
+				img.SetFromPixbuf(pixbuf)
+			})
+		}
+	}()
+}
+
+// makeThumbnail writes the thumbnail to the cache path. GTK is only used on the main thread.
+func makeThumbnail(job thumbnailJob, cachePath string) error {
+	if job.kind == themeKindCursor {
+		return extractCursorImage(job.source, cachePath)
+	}
+
+	done := make(chan error, 1)
+	glib.IdleAdd(func() {
+		if job.kind == themeKindGtk {
+			renderWidgetsThumbnail(job.source, cachePath, done)
+		} else {
+			done <- renderIconsThumbnail(filepath.Base(filepath.Dir(job.source)), cachePath)
+		}
+	})
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(thumbnailTimeout):
+		return errors.New("timed out")
+	}
+}
+
+// extractCursorImage converts the xcursor file to png with xcur2png, like setUpCursorsPreview does
+func extractCursorImage(source, cachePath string) error {
+	dir, err := os.MkdirTemp(tempDir(), "nwg-look-thumbnail-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command("xcur2png", source, "-d", dir, "-c", dir, "-q")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("xcur2png: %w", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.Base(source)+"_000.png"))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, data, 0o644)
+}
+
+// renderWidgetsThumbnail draws a button, a check button and an entry styled with the theme CSS off-screen,
+// and sends the result of saving the picture to done
+func renderWidgetsThumbnail(css, cachePath string, done chan<- error) {
+	provider, _ := gtk.CssProviderNew()
+	if err := provider.LoadFromPath(css); err != nil {
+		done <- err
+		return
+	}
+
+	win, _ := gtk.OffscreenWindowNew()
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.SetProperty("margin", 6)
+	win.Add(box)
+
+	btn, _ := gtk.ButtonNewWithLabel("Aa")
+	box.PackStart(btn, false, false, 0)
+	check, _ := gtk.CheckButtonNew()
+	check.SetActive(true)
+	check.SetProperty("valign", gtk.ALIGN_CENTER)
+	box.PackStart(check, false, false, 0)
+	entry, _ := gtk.EntryNew()
+	entry.SetWidthChars(4)
+	entry.SetText("abc")
+	box.PackStart(entry, false, false, 0)
+
+	// providers of style contexts don't cascade to child widgets
+	widgets := []*gtk.Widget{&win.Widget, &box.Widget, &btn.Widget, &check.Widget, &entry.Widget}
+	if label, err := btn.GetChild(); err == nil {
+		widgets = append(widgets, label.ToWidget())
+	}
+	for _, w := range widgets {
+		styleContext, _ := w.GetStyleContext()
+		styleContext.AddProvider(provider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+	}
+
+	// the window has been drawn on damage-event, the picture is taken when GTK is done with it
+	taken := false
+	win.Connect("damage-event", func() bool {
+		if taken {
+			return false
+		}
+		taken = true
+		glib.IdleAdd(func() {
+			pixbuf, err := win.GetPixbuf()
+			if err == nil {
+				err = pixbuf.SavePNG(cachePath, 9)
+			}
+			win.Destroy()
+			done <- err
+		})
+		return false
+	})
+	win.ShowAll()
+}
+
+// renderIconsThumbnail saves thumbnailIcons, as resolved by the icon theme, side by side
+func renderIconsThumbnail(themeName, cachePath string) error {
+	iconTheme, err := gtk.IconThemeNew()
+	if err != nil {
+		return err
+	}
+	setCustomIconTheme(iconTheme, themeName)
+
+	dest, err := gdk.PixbufNew(gdk.COLORSPACE_RGB, true, 8, thumbnailWidths[themeKindIcon], thumbnailIconSize)
+	if err != nil {
+		return err
+	}
+	dest.Fill(0)
+	for i, name := range thumbnailIcons {
+		icon, err := iconTheme.LoadIcon(name, thumbnailIconSize, gtk.ICON_LOOKUP_FORCE_SIZE)
+		if err != nil {
+			continue
+		}
+		x := i * (thumbnailIconSize + 4)
+		icon.Composite(dest, x, 0, thumbnailIconSize, thumbnailIconSize, float64(x), 0, 1, 1, gdk.INTERP_BILINEAR, 255)
+	}
+	return dest.SavePNG(cachePath, 9)
+}
+
+// setCustomIconTheme makes the icon theme look icons up in the named theme. gotk3 has no binding for it.
+func setCustomIconTheme(iconTheme *gtk.IconTheme, name string) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	C.gtk_icon_theme_set_custom_theme((*C.GtkIconTheme)(unsafe.Pointer(iconTheme.Theme)), (*C.gchar)(cName))
+}
@@ -12,8 +12,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/nwg-piotr/nwg-look/stylepak"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -30,6 +32,7 @@ func loadPreferences() {
 	preferencesFile := filepath.Join(cH, "/nwg-look/config")
 	if !pathExists(preferencesFile) {
 		log.Infof("%s file not found, creating", preferencesFile)
+		firstRun = true
 		makeDir(filepath.Join(cH, "/nwg-look/"))
 		preferences = programSettingsNewWithDefaults()
 		savePreferences()
@@ -62,30 +65,29 @@ func savePreferences() {
 	}
 }
 
+// loadXftDpi sets the Xft DPI from preferences, if set there
+func loadXftDpi() {
+	if preferences.XftDpi > 0 {
+		gtkConfig.xftDpi = preferences.XftDpi * 1024
+	}
+}
+
 func loadGtkConfig() {
 	// parse gtk settings file
-	originalGtkConfig = []string{}
 	configFile := filepath.Join(configHome(), "gtk-3.0/settings.ini")
 	if pathExists(configFile) {
-		lines, err := loadTextFile(configFile)
+		doc, err := loadIni(configFile)
 		if err == nil {
 			log.Infof(">>> Parsing original %s", configFile)
 		} else {
 			log.Warnf("Couldn't load %s", configFile)
+			doc = parseIni("")
 		}
 
-		for _, line := range lines {
-			// In case users settings.ini had some lines we didn't expect,
-			// we'll append them back from here.
-			if !strings.HasPrefix(line, "[") {
-				originalGtkConfig = append(originalGtkConfig, line)
-			}
-
-			if !strings.HasPrefix(line, "[") && !strings.HasPrefix(line, "#") &&
-				strings.Contains(line, "=") {
-				parts := strings.Split(line, "=")
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
+		for _, line := range doc.lines {
+			if line.section == "Settings" && line.key != "" {
+				key := line.key
+				value := line.value
 				switch key {
 				case "gtk-theme-name":
 					gtkConfig.themeName = value
@@ -93,6 +95,8 @@ func loadGtkConfig() {
 					gtkConfig.iconThemeName = value
 				case "gtk-font-name":
 					gtkConfig.fontName = value
+				case "gtk-key-theme-name":
+					gtkConfig.keyThemeName = value
 				case "gtk-cursor-theme-name":
 					gtkConfig.cursorThemeName = value
 				case "gtk-cursor-theme-size":
@@ -114,6 +118,8 @@ func loadGtkConfig() {
 					gtkConfig.enableEventSounds = value == "1"
 				case "gtk-enable-input-feedback-sounds":
 					gtkConfig.enableInputFeedbackSounds = value == "1"
+				case "gtk-sound-theme-name":
+					gtkConfig.soundThemeName = value
 				case "gtk-xft-antialias":
 					gtkConfig.xftAntialias = intValue(value)
 				case "gtk-xft-hinting":
@@ -122,8 +128,20 @@ func loadGtkConfig() {
 					gtkConfig.xftHintstyle = value
 				case "gtk-xft-rgba":
 					gtkConfig.xftRgba = value
+				case "gtk-xft-dpi":
+					gtkConfig.xftDpi = intValue(value)
 				case "gtk-application-prefer-dark-theme":
 					gtkConfig.applicationPreferDarkTheme = value == "1"
+				case "gtk-enable-animations":
+					gtkConfig.enableAnimations = value != "0" && value != "false"
+				case "gtk-cursor-blink":
+					gtkConfig.cursorBlink = value != "0" && value != "false"
+				case "gtk-cursor-blink-time":
+					gtkConfig.cursorBlinkTime = intValue(value)
+				case "gtk-cursor-blink-timeout":
+					gtkConfig.cursorBlinkTimeout = intValue(value)
+				case "gtk-decoration-layout":
+					gtkConfig.decorationLayout = value
 				default:
 					log.Warnf("Unsupported config key: %s", key)
 				}
@@ -135,6 +153,7 @@ func loadGtkConfig() {
 	log.Debugf("gtk-theme-name: %s", gtkConfig.themeName)
 	log.Debugf("gtk-icon-theme-name: %s", gtkConfig.iconThemeName)
 	log.Debugf("gtk-font-name: %s", gtkConfig.fontName)
+	log.Debugf("gtk-key-theme-name: %s", gtkConfig.keyThemeName)
 	log.Debugf("gtk-cursor-theme-name: %s", gtkConfig.cursorThemeName)
 	log.Debugf("gtk-cursor-theme-size: %v", gtkConfig.cursorThemeSize)
 	log.Debugf("gtk-toolbar-style: %s", gtkConfig.toolbarStyle)
@@ -143,11 +162,18 @@ func loadGtkConfig() {
 	log.Debugf("gtk-menu-images: %v", gtkConfig.menuImages)
 	log.Debugf("gtk-enable-event-sounds: %v", gtkConfig.enableEventSounds)
 	log.Debugf("gtk-enable-input-feedback-sounds: %v", gtkConfig.enableInputFeedbackSounds)
+	log.Debugf("gtk-sound-theme-name: %s", gtkConfig.soundThemeName)
 	log.Debugf("gtk-xft-antialias: %v", gtkConfig.xftAntialias)
 	log.Debugf("gtk-xft-hinting: %v", gtkConfig.xftHinting)
 	log.Debugf("gtk-xft-hintstyle: %v", gtkConfig.xftHintstyle)
 	log.Debugf("gtk-xft-rgba: %v", gtkConfig.xftRgba)
+	log.Debugf("gtk-xft-dpi: %v", gtkConfig.xftDpi)
 	log.Debugf("gtk-application-prefer-dark-theme: %v", gtkConfig.applicationPreferDarkTheme)
+	log.Debugf("gtk-enable-animations: %v", gtkConfig.enableAnimations)
+	log.Debugf("gtk-cursor-blink: %v", gtkConfig.cursorBlink)
+	log.Debugf("gtk-cursor-blink-time: %v", gtkConfig.cursorBlinkTime)
+	log.Debugf("gtk-cursor-blink-timeout: %v", gtkConfig.cursorBlinkTimeout)
+	log.Debugf("gtk-decoration-layout: %s", gtkConfig.decorationLayout)
 }
 
 func intValue(s string) int {
@@ -162,145 +188,21 @@ func intValue(s string) int {
 func readGsettings() {
 	log.Info(">>> Reading gsettings")
 
-	val, err := getGsettingsValue("org.gnome.desktop.interface", "gtk-theme")
-	if err == nil {
-		gsettings.gtkTheme = val
-		log.Infof("gtk-theme: %s", gsettings.gtkTheme)
-	} else {
-		log.Warnf("Couldn't read gtk-theme, leaving default %s",
-			gsettings.gtkTheme)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "icon-theme")
-	if err == nil {
-		gsettings.iconTheme = val
-		log.Infof("icon-theme: %s", gsettings.iconTheme)
-	} else {
-		log.Warnf("Couldn't read icon-theme, leaving default %s",
-			gsettings.iconTheme)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "font-name")
-	if err == nil {
-		gsettings.fontName = val
-		log.Infof("font-name: %s", gsettings.fontName)
-	} else {
-		log.Warnf("Couldn't read font-name, leaving default %s",
-			gsettings.fontName)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "cursor-theme")
-	if err == nil {
-		gsettings.cursorTheme = val
-		log.Infof("cursor-theme: %s", gsettings.cursorTheme)
-	} else {
-		gsettings.cursorTheme = ""
-		log.Warnf("Couldn't read cursor-theme, leaving default %s",
-			gsettings.cursorTheme)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "cursor-size")
-	if err == nil {
-		v, e := strconv.Atoi(val)
-		if e == nil {
-			gsettings.cursorSize = v
-			log.Infof("cursor-size: %v", gsettings.cursorSize)
-		}
-	} else {
-		log.Warnf("Couldn't read cursorSize, leaving default %d",
-			gsettings.cursorSize)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "toolbar-style")
-	if err == nil {
-		gsettings.toolbarStyle = val
-		log.Infof("toolbar-style: %s", gsettings.toolbarStyle)
-	} else {
-		log.Warnf("Couldn't read toolbar-style, leaving default %s",
-			gsettings.toolbarStyle)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "toolbar-icons-size")
-	if err == nil {
-		gsettings.toolbarIconsSize = val
-		log.Infof("toolbar-icons-size: %s", gsettings.toolbarIconsSize)
-	} else {
-		log.Warnf("Couldn't read toolbar-icons-size, leaving default %s",
-			gsettings.toolbarIconsSize)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "font-hinting")
-	if err == nil {
-		gsettings.fontHinting = val
-		log.Infof("font-hinting: %s", gsettings.fontHinting)
-	} else {
-		log.Warnf("Couldn't read font-hinting, leaving default %s",
-			gsettings.fontHinting)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "font-antialiasing")
-	if err == nil {
-		gsettings.fontAntialiasing = val
-		log.Infof("font-antialiasing: %s", gsettings.fontAntialiasing)
-	} else {
-		log.Warnf("Couldn't read font-antialiasing, leaving default %s",
-			gsettings.fontAntialiasing)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "font-rgba-order")
-	if err == nil {
-		gsettings.fontRgbaOrder = val
-		log.Infof("font-rgba-order: %s", gsettings.fontRgbaOrder)
-	} else {
-		log.Warnf("Couldn't read font-rgba-order, leaving default %s",
-			gsettings.fontRgbaOrder)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "text-scaling-factor")
-	if err == nil {
-		v, e := strconv.ParseFloat(val, 32)
-		if e == nil {
-			gsettings.textScalingFactor = v
-			log.Infof("text-scaling-factor: %v", gsettings.textScalingFactor)
-		}
-	} else {
-		log.Warnf("Couldn't read textScalingFactor, leaving default %f",
-			gsettings.textScalingFactor)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.interface", "color-scheme")
-	if err == nil {
-		gsettings.colorScheme = val
-		log.Infof("color-scheme: %s", gsettings.colorScheme)
-	} else {
-		log.Warnf("Couldn't read color-scheme, leaving default %s",
-			gsettings.colorScheme)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.sound", "event-sounds")
-	if err == nil {
-		if val == "true" {
-			gsettings.eventSounds = true
-		} else {
-			gsettings.eventSounds = false
+	gsettingsLoaded = make(map[string]string)
+	for _, f := range gsettingsFields {
+		val, err := getGsettingsValue(f.schema, f.key)
+		if err == nil {
+			err = f.parse(&gsettings, val)
 		}
-		log.Infof("event-sounds: %v", gsettings.eventSounds)
-	} else {
-		log.Warnf("Couldn't read event-sounds, leaving default %v",
-			gsettings.eventSounds)
-	}
-
-	val, err = getGsettingsValue("org.gnome.desktop.sound", "input-feedback-sounds")
-	if err == nil {
-		if val == "true" {
-			gsettings.inputFeedbackSounds = true
-		} else {
-			gsettings.inputFeedbackSounds = false
+		if err != nil {
+			if f.key == "cursor-theme" {
+				gsettings.cursorTheme = ""
+			}
+			log.Warnf("Couldn't read %s, leaving default %s", f.key, f.String(&gsettings))
+			continue
 		}
-		log.Infof("input-feedback-sounds: %v", gsettings.inputFeedbackSounds)
-	} else {
-		log.Warnf("Couldn't read input-feedback-sounds, leaving default %v",
-			gsettings.inputFeedbackSounds)
+		gsettingsLoaded[f.key] = f.String(&gsettings)
+		log.Infof("%s: %s", f.key, f.String(&gsettings))
 	}
 }
 
@@ -311,34 +213,38 @@ func saveGsettingsBackup() {
 
 	lines := []string{"# Generated by nwg-look, do not edit this file."}
 
-	for _, key := range []string{
-		"gtk-theme",
-		"icon-theme",
-		"font-name",
-		"cursor-theme",
-		"cursor-size",
-		"toolbar-style",
-		"toolbar-icons-size",
-		"font-hinting",
-		"font-antialiasing",
-		"font-rgba-order",
-		"text-scaling-factor",
-		"color-scheme"} {
-		val, err := getGsettingsValue("org.gnome.desktop.interface", key)
+	for _, f := range gsettingsFields {
+		val, err := getGsettingsValue(f.schema, f.key)
 		if err == nil {
-			line := fmt.Sprintf("%s=%s", key, val)
-			lines = append(lines, line)
+			lines = append(lines, fmt.Sprintf("%s=%s", f.key, val))
 		} else {
-			log.Warnf("Couldn't get gsettings key: %s", key)
+			log.Warnf("Couldn't get gsettings key: %s", f.key)
 		}
 	}
-	for _, key := range []string{"event-sounds", "input-feedback-sounds"} {
-		val, err := getGsettingsValue("org.gnome.desktop.sound", key)
-		if err == nil {
-			line := fmt.Sprintf("%s=%s", key, val)
-			lines = append(lines, line)
-		} else {
-			log.Warnf("Couldn't get gsettings key: %s", key)
+
+	// other keys of the schemas editable on the Advanced page, as GVariant text, if changed from defaults.
+	// Sections are written even if empty: applyGsettingsFromFile resets keys they don't list.
+	defaults := gschemaDefaults(advancedSchemas)
+	for _, schema := range advancedSchemas {
+		values, err := getGsettingsSchemaValues(schema)
+		if err != nil {
+			log.Warnf("Couldn't list gsettings schema: %s", schema)
+			continue
+		}
+		if defaults[schema] == nil {
+			log.Warnf("Schema source of %s not found, backing up all keys", schema)
+		}
+		var keys []string
+		for key := range values {
+			if isGsettingsField(schema, key) || isGschemaDefault(defaults[schema], key, values[key]) {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		lines = append(lines, fmt.Sprintf("[%s]", schema))
+		for _, key := range keys {
+			lines = append(lines, fmt.Sprintf("%s=%s", key, values[key]))
 		}
 	}
 
@@ -358,137 +264,28 @@ func getGsettingsValue(schema, key string) (string, error) {
 	return "", err
 }
 
+// applyGsettings sets keys whose values differ from those loaded by readGsettings, or all keys if
+// they haven't been loaded, so that keys not touched in nwg-look keep values set by other tools
 func applyGsettings() {
-	gnomeSchema := "org.gnome.desktop.interface"
 	log.Info(">>> Applying gsettings")
-	log.Infof(">> %s", gnomeSchema)
-
-	cmd := exec.Command("gsettings", "set", gnomeSchema, "gtk-theme", gsettings.gtkTheme)
-	err := cmd.Run()
-	if err != nil {
-		log.Warnf("gtk-theme: %s", err)
-	} else {
-		log.Infof("gtk-theme: %s OK", gsettings.gtkTheme)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "icon-theme", gsettings.iconTheme)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("icon-theme: %s", err)
-	} else {
-		log.Infof("icon-theme: %s OK", gsettings.iconTheme)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "cursor-theme", gsettings.cursorTheme)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("cursor-theme: %s", err)
-	} else {
-		log.Infof("cursor-theme: %s OK", gsettings.cursorTheme)
-	}
 
-	var val string
-
-	val = strconv.Itoa(gsettings.cursorSize)
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "cursor-size", val)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("cursor-size: %s", err)
-	} else {
-		log.Infof("cursor-size: %s OK", val)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "font-name", gsettings.fontName)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("font-name: %s %s", gsettings.fontName, err)
-	} else {
-		log.Infof("font-name: %s OK", gsettings.fontName)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "font-hinting", gsettings.fontHinting)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("font-hinting: %s %s", gsettings.fontHinting, err)
-	} else {
-		log.Infof("font-hinting: %s OK", gsettings.fontHinting)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "font-antialiasing", gsettings.fontAntialiasing)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("font-antialiasing: %s %s", gsettings.fontAntialiasing, err)
-	} else {
-		log.Infof("font-antialiasing: %s OK", gsettings.fontAntialiasing)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "font-rgba-order", gsettings.fontRgbaOrder)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("font-rgba-order: %s %s", gsettings.fontRgbaOrder, err)
-	} else {
-		log.Infof("font-rgba-order: %s OK", gsettings.fontRgbaOrder)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "text-scaling-factor", fmt.Sprintf("%f", gsettings.textScalingFactor))
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("text-scaling-factor: %f %s", gsettings.textScalingFactor, err)
-	} else {
-		log.Infof("text-scaling-factor: %v OK", gsettings.textScalingFactor)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "toolbar-style", gsettings.toolbarStyle)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("toolbar-style: %s %s", gsettings.toolbarStyle, err)
-	} else {
-		log.Infof("toolbar-style: %s OK", gsettings.toolbarStyle)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "toolbar-icons-size", gsettings.toolbarIconsSize)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("toolbar-icons-size: %s %s", gsettings.toolbarIconsSize, err)
-	} else {
-		log.Infof("toolbar-icons-size: %s OK", gsettings.toolbarIconsSize)
-	}
-
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "color-scheme", gsettings.colorScheme)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("color-scheme: %s %s", gsettings.colorScheme, err)
-	} else {
-		log.Infof("color-scheme: %s OK", gsettings.colorScheme)
-	}
-
-	gnomeSchema = "org.gnome.desktop.sound"
-	log.Infof(">> %s", gnomeSchema)
-
-	if gsettings.eventSounds {
-		val = "true"
-	} else {
-		val = "false"
-	}
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "event-sounds", val)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("event-sounds: %s %s", val, err)
-	} else {
-		log.Infof("event-sounds: %s OK", val)
-	}
-
-	if gsettings.inputFeedbackSounds {
-		val = "true"
-	} else {
-		val = "false"
-	}
-	cmd = exec.Command("gsettings", "set", gnomeSchema, "input-feedback-sounds", val)
-	err = cmd.Run()
-	if err != nil {
-		log.Warnf("input-feedback-sounds: %s %s", val, err)
-	} else {
-		log.Infof("input-feedback-sounds: %s OK", val)
+	schema := ""
+	for _, f := range gsettingsFields {
+		val := f.String(&gsettings)
+		if loaded, ok := gsettingsLoaded[f.key]; ok && loaded == val {
+			continue
+		}
+		if f.schema != schema {
+			schema = f.schema
+			log.Infof(">> %s", schema)
+		}
+		cmd := exec.Command("gsettings", "set", f.schema, f.key, val)
+		if err := cmd.Run(); err != nil {
+			log.Warnf("%s: %s %s", f.key, val, err)
+		} else {
+			gsettingsLoaded[f.key] = val
+			log.Infof("%s: %s OK", f.key, val)
+		}
 	}
 }
 
@@ -500,54 +297,40 @@ func applyGsettingsFromFile() {
 		if err != nil {
 			log.Fatalf("Failed loading file: %s", err)
 		}
-		var key, value string
+		var section string
+		listed := make(map[string]map[string]bool) // keys of advancedSchemas sections
 		for _, line := range lines {
+			if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+				section = line[1 : len(line)-1]
+				if isIn(advancedSchemas, section) && listed[section] == nil {
+					listed[section] = make(map[string]bool)
+				}
+				continue
+			}
+			if section != "" {
+				// [schema] sections hold GVariant text of other keys from advancedSchemas. Backups of older
+				// versions also repeat keys of the plain part, these are set from the plain part only.
+				key, value, ok := strings.Cut(line, "=")
+				if ok && isIn(advancedSchemas, section) && !isGsettingsField(section, key) {
+					setAdvancedGsetting(section, key, value)
+					listed[section][key] = true
+				}
+				continue
+			}
 			if !strings.HasPrefix(line, "#") {
 				parts := strings.Split(line, "=")
 				if len(parts) == 2 {
-					key = parts[0]
-					value = parts[1]
-
-					switch key {
-					case "gtk-theme":
-						gsettings.gtkTheme = value
-					case "icon-theme":
-						gsettings.iconTheme = value
-					case "font-name":
-						gsettings.fontName = value
-					case "cursor-theme":
-						gsettings.cursorTheme = value
-					case "cursor-size":
-						v, err := strconv.Atoi(value)
-						if err == nil {
-							gsettings.cursorSize = v
-						}
-					case "toolbar-style":
-						gsettings.toolbarStyle = value
-					case "toolbar-icons-size":
-						gsettings.toolbarIconsSize = value
-					case "font-hinting":
-						gsettings.fontHinting = value
-					case "font-antialiasing":
-						gsettings.fontAntialiasing = value
-					case "font-rgba-order":
-						gsettings.fontRgbaOrder = value
-					case "text-scaling-factor":
-						v, err := strconv.ParseFloat(value, 64)
-						if err == nil {
-							gsettings.textScalingFactor = v
+					if f, ok := gsettingsFieldByKey(parts[0]); ok {
+						if err := f.parse(&gsettings, parts[1]); err != nil {
+							log.Warnf("%s: %s", parts[0], err)
 						}
-					case "event-sounds":
-						gsettings.eventSounds = value == "true"
-					case "input-feedback-sounds":
-						gsettings.inputFeedbackSounds = value == "true"
-					case "color-scheme":
-						gsettings.colorScheme = value
 					}
 				}
 			}
 		}
+		resetUnlistedAdvancedGsettings(listed)
 		applyGsettings()
+		applyAdvancedGsettings()
 	} else {
 		log.Warnf("Couldn't find file: %s", gsettingsFile)
 		os.Exit(1)
@@ -561,11 +344,12 @@ func saveGtkIni3() {
 	}
 	log.Infof(">>> Exporting %s", configFile)
 
-	lines := []string{"[Settings]"}
+	var lines []string
 
 	lines = append(lines, fmt.Sprintf("gtk-theme-name=%s", gsettings.gtkTheme))
 	lines = append(lines, fmt.Sprintf("gtk-icon-theme-name=%s", gsettings.iconTheme))
 	lines = append(lines, fmt.Sprintf("gtk-font-name=%s", gsettings.fontName))
+	lines = append(lines, fmt.Sprintf("gtk-key-theme-name=%s", gsettings.keyTheme))
 	lines = append(lines, fmt.Sprintf("gtk-cursor-theme-name=%s", gsettings.cursorTheme))
 	lines = append(lines, fmt.Sprintf("gtk-cursor-theme-size=%v", gsettings.cursorSize))
 
@@ -599,6 +383,7 @@ func saveGtkIni3() {
 		v = 0
 	}
 	lines = append(lines, fmt.Sprintf("gtk-enable-input-feedback-sounds=%v", v))
+	lines = append(lines, fmt.Sprintf("gtk-sound-theme-name=%s", gsettings.soundTheme))
 
 	if gsettings.fontAntialiasing != "none" {
 		v = 1
@@ -629,6 +414,10 @@ func saveGtkIni3() {
 
 	lines = append(lines, fmt.Sprintf("gtk-xft-rgba=%s", gsettings.fontRgbaOrder))
 
+	if gtkConfig.xftDpi > 0 {
+		lines = append(lines, fmt.Sprintf("gtk-xft-dpi=%v", gtkConfig.xftDpi))
+	}
+
 	if gsettings.colorScheme == "prefer-dark" {
 		v = 1
 	} else {
@@ -636,18 +425,25 @@ func saveGtkIni3() {
 	}
 	lines = append(lines, fmt.Sprintf("gtk-application-prefer-dark-theme=%v", v))
 
-	// append unsupported lines / comments from the original settings.ini file
-	for _, l := range originalGtkConfig {
-		if l != "" && !isSupported(l) {
-			lines = append(lines, l)
-		}
+	if gsettings.enableAnimations {
+		v = 1
+	} else {
+		v = 0
 	}
+	lines = append(lines, fmt.Sprintf("gtk-enable-animations=%v", v))
 
-	for _, l := range lines {
-		log.Debug(l)
+	if gsettings.cursorBlink {
+		v = 1
+	} else {
+		v = 0
 	}
+	lines = append(lines, fmt.Sprintf("gtk-cursor-blink=%v", v))
+	lines = append(lines, fmt.Sprintf("gtk-cursor-blink-time=%v", gsettings.cursorBlinkTime))
+	lines = append(lines, fmt.Sprintf("gtk-cursor-blink-timeout=%v", gsettings.cursorBlinkTimeout))
+	lines = append(lines, fmt.Sprintf("gtk-decoration-layout=%s", gtkDecorationLayout(gsettings.buttonLayout)))
 
-	saveTextFile(lines, configFile)
+	// unsupported lines / comments from the original settings.ini file are preserved
+	saveSettingsIni(configFile, lines, isSupported)
 }
 
 func saveGtkIni4() {
@@ -657,7 +453,7 @@ func saveGtkIni4() {
 	}
 	log.Infof(">>> Exporting %s", configFile)
 
-	lines := []string{"[Settings]"}
+	var lines []string
 
 	lines = append(lines, fmt.Sprintf("gtk-theme-name=%s", gsettings.gtkTheme))
 	lines = append(lines, fmt.Sprintf("gtk-icon-theme-name=%s", gsettings.iconTheme))
@@ -673,11 +469,106 @@ func saveGtkIni4() {
 	}
 	lines = append(lines, fmt.Sprintf("gtk-application-prefer-dark-theme=%v", v))
 
-	for _, l := range lines {
-		log.Debug(l)
+	if gsettings.fontAntialiasing != "none" {
+		v = 1
+	} else {
+		v = 0
 	}
+	lines = append(lines, fmt.Sprintf("gtk-xft-antialias=%v", v))
 
-	saveTextFile(lines, configFile)
+	if gsettings.fontHinting != "none" {
+		v = 1
+	} else {
+		v = 0
+	}
+	lines = append(lines, fmt.Sprintf("gtk-xft-hinting=%v", v))
+
+	var fh string
+	switch gsettings.fontHinting {
+	case "slight":
+		fh = "hintslight"
+	case "medium":
+		fh = "hintmedium"
+	case "full":
+		fh = "hintfull"
+	default:
+		fh = "hintnone"
+	}
+	lines = append(lines, fmt.Sprintf("gtk-xft-hintstyle=%s", fh))
+
+	lines = append(lines, fmt.Sprintf("gtk-xft-rgba=%s", gsettings.fontRgbaOrder))
+
+	if gtkConfig.xftDpi > 0 {
+		lines = append(lines, fmt.Sprintf("gtk-xft-dpi=%v", gtkConfig.xftDpi))
+	}
+
+	lines = append(lines, fmt.Sprintf("gtk-decoration-layout=%s", gtkDecorationLayout(gsettings.buttonLayout)))
+
+	if gsettings.enableAnimations {
+		v = 1
+	} else {
+		v = 0
+	}
+	lines = append(lines, fmt.Sprintf("gtk-enable-animations=%v", v))
+
+	if gsettings.overlayScrolling {
+		v = 1
+	} else {
+		v = 0
+	}
+	lines = append(lines, fmt.Sprintf("gtk-overlay-scrolling=%v", v))
+
+	if gsettings.cursorBlink {
+		v = 1
+	} else {
+		v = 0
+	}
+	lines = append(lines, fmt.Sprintf("gtk-cursor-blink=%v", v))
+	lines = append(lines, fmt.Sprintf("gtk-cursor-blink-time=%v", gsettings.cursorBlinkTime))
+	lines = append(lines, fmt.Sprintf("gtk-cursor-blink-timeout=%v", gsettings.cursorBlinkTimeout))
+
+	if gsettings.eventSounds {
+		v = 1
+	} else {
+		v = 0
+	}
+	lines = append(lines, fmt.Sprintf("gtk-enable-event-sounds=%v", v))
+
+	if gsettings.inputFeedbackSounds {
+		v = 1
+	} else {
+		v = 0
+	}
+	lines = append(lines, fmt.Sprintf("gtk-enable-input-feedback-sounds=%v", v))
+	lines = append(lines, fmt.Sprintf("gtk-sound-theme-name=%s", gsettings.soundTheme))
+
+	// unsupported lines / comments from the original settings.ini file are preserved
+	saveSettingsIni(configFile, lines, isSupportedGtk4)
+}
+
+func isSupportedGtk4(line string) bool {
+	supported := []string{
+		"gtk-theme-name",
+		"gtk-icon-theme-name",
+		"gtk-font-name",
+		"gtk-cursor-theme-name",
+		"gtk-cursor-theme-size",
+		"gtk-application-prefer-dark-theme",
+		"gtk-xft-",
+		"gtk-decoration-layout",
+		"gtk-enable-animations",
+		"gtk-overlay-scrolling",
+		"gtk-cursor-blink",
+		"gtk-enable-event-sounds",
+		"gtk-enable-input-feedback-sounds",
+		"gtk-sound-theme-name",
+	}
+	for _, d := range supported {
+		if strings.HasPrefix(line, d) {
+			return true
+		}
+	}
+	return false
 }
 
 func isSupported(line string) bool {
@@ -685,6 +576,7 @@ func isSupported(line string) bool {
 		"gtk-theme-name",
 		"gtk-icon-theme-name",
 		"gtk-font-name",
+		"gtk-key-theme-name",
 		"gtk-cursor-theme-name",
 		"gtk-cursor-theme-size",
 		"gtk-toolbar-style",
@@ -693,11 +585,16 @@ func isSupported(line string) bool {
 		"gtk-menu-images",
 		"gtk-enable-event-sounds",
 		"gtk-enable-input-feedback-sounds",
+		"gtk-sound-theme-name",
 		"gtk-xft-antialias",
 		"gtk-xft-hinting",
 		"gtk-xft-hintstyle",
 		"gtk-xft-rgba",
+		"gtk-xft-dpi",
 		"gtk-application-prefer-dark-theme",
+		"gtk-enable-animations",
+		"gtk-cursor-blink",
+		"gtk-decoration-layout",
 	}
 	for _, d := range supported {
 		if strings.HasPrefix(line, d) {
@@ -707,26 +604,40 @@ func isSupported(line string) bool {
 	return false
 }
 
-func saveGtkRc20() {
-	home := os.Getenv("HOME")
-	var configFile string
-	if os.Getenv("GTK2_RC_FILES") != "" {
-		configFile = os.Getenv("GTK2_RC_FILES")
-	} else {
-		configFile = filepath.Join(home, ".gtkrc-2.0")
+const (
+	gtkRc20BlockBegin = "# BEGIN nwg-look: settings below are managed by nwg-look"
+	gtkRc20BlockEnd   = "# END nwg-look"
+)
+
+// gtkRc20File returns the first writable entry of GTK2_RC_FILES, or ~/.gtkrc-2.0 if the variable is not set
+func gtkRc20File() string {
+	rcFiles := os.Getenv("GTK2_RC_FILES")
+	if rcFiles == "" {
+		return filepath.Join(os.Getenv("HOME"), ".gtkrc-2.0")
 	}
-	log.Infof(">>> Exporting %s", configFile)
+	for _, f := range strings.Split(rcFiles, ":") {
+		if f != "" && isWritable(f) {
+			return f
+		}
+		log.Debugf("GTK2_RC_FILES entry not writable: '%s'", f)
+	}
+	return ""
+}
 
-	lines := []string{
-		"# DO NOT EDIT! This file will be overwritten by nwg-look.",
-		"# Any customization should be done in ~/.gtkrc-2.0.mine instead.",
-		"",
+func isWritable(path string) bool {
+	if pathExists(path) {
+		return syscall.Access(path, 2) == nil // W_OK
 	}
-	lines = append(lines, fmt.Sprintf("include \"%s/.gtkrc-2.0.mine\"", home))
+	return syscall.Access(filepath.Dir(path), 2) == nil
+}
+
+func gtkRc20Lines() []string {
+	var lines []string
 
 	lines = append(lines, fmt.Sprintf("gtk-theme-name=\"%s\"", gsettings.gtkTheme))
 	lines = append(lines, fmt.Sprintf("gtk-icon-theme-name=\"%s\"", gsettings.iconTheme))
 	lines = append(lines, fmt.Sprintf("gtk-font-name=\"%s\"", gsettings.fontName))
+	lines = append(lines, fmt.Sprintf("gtk-key-theme-name=\"%s\"", gsettings.keyTheme))
 	lines = append(lines, fmt.Sprintf("gtk-cursor-theme-name=\"%s\"", gsettings.cursorTheme))
 	lines = append(lines, fmt.Sprintf("gtk-cursor-theme-size=%v", gsettings.cursorSize))
 
@@ -758,6 +669,7 @@ func saveGtkRc20() {
 		v = 0
 	}
 	lines = append(lines, fmt.Sprintf("gtk-enable-input-feedback-sounds=%v", v))
+	lines = append(lines, fmt.Sprintf("gtk-sound-theme-name=\"%s\"", gsettings.soundTheme))
 
 	if gsettings.fontAntialiasing != "none" {
 		v = 1
@@ -788,11 +700,118 @@ func saveGtkRc20() {
 
 	lines = append(lines, fmt.Sprintf("gtk-xft-rgba=\"%s\"", gsettings.fontRgbaOrder))
 
-	if gtkConfig.applicationPreferDarkTheme {
+	if gsettings.colorScheme == "prefer-dark" {
 		v = 1
 	} else {
 		v = 0
 	}
+	lines = append(lines, fmt.Sprintf("gtk-application-prefer-dark-theme=%v", v))
+
+	return lines
+}
+
+// gtkRcKey returns the setting name of a gtkrc `key = value` line, or an empty string
+func gtkRcKey(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return ""
+	}
+	key, _, ok := strings.Cut(line, "=")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// mergeGtkRc20 replaces the nwg-look block in the existing gtkrc lines, leaving user content untouched
+func mergeGtkRc20(original, managed []string) []string {
+	block := []string{gtkRc20BlockBegin}
+	block = append(block, managed...)
+	block = append(block, gtkRc20BlockEnd)
+
+	managedKeys := make(map[string]bool)
+	for _, l := range managed {
+		managedKeys[gtkRcKey(l)] = true
+	}
+
+	// files written by nwg-look before the managed block was introduced
+	legacy := false
+	for _, l := range original {
+		if strings.Contains(l, "This file will be overwritten by nwg-look") {
+			legacy = true
+			break
+		}
+	}
+
+	var lines []string
+	inBlock, blockWritten := false, false
+	for _, l := range original {
+		trimmed := strings.TrimSpace(l)
+		switch {
+		case trimmed == gtkRc20BlockBegin:
+			inBlock = true
+		case trimmed == gtkRc20BlockEnd && inBlock:
+			inBlock = false
+			if !blockWritten {
+				lines = append(lines, block...)
+				blockWritten = true
+			}
+		case inBlock:
+			continue
+		case legacy && (strings.HasPrefix(trimmed, "# DO NOT EDIT!") ||
+			strings.HasPrefix(trimmed, "# Any customization should be done in") ||
+			managedKeys[gtkRcKey(trimmed)]):
+			continue
+		default:
+			if managedKeys[gtkRcKey(trimmed)] {
+				log.Warnf("gtkrc-2.0: '%s' is set outside the nwg-look block", gtkRcKey(trimmed))
+			}
+			lines = append(lines, l)
+		}
+	}
+
+	if legacy {
+		for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+			lines = lines[1:]
+		}
+	}
+
+	if !blockWritten {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+	}
+
+	return lines
+}
+
+func saveGtkRc20() {
+	themePath := gtkThemePath(gsettings.gtkTheme)
+	if themePath == "" || !pathExists(filepath.Join(themePath, "gtk-2.0")) {
+		log.Warnf("'%s' theme has no gtk-2.0 directory, skipping gtkrc-2.0 export", gsettings.gtkTheme)
+		return
+	}
+
+	configFile := gtkRc20File()
+	if configFile == "" {
+		log.Warnf("No writable GTK2_RC_FILES entry in '%s', skipping gtkrc-2.0 export", os.Getenv("GTK2_RC_FILES"))
+		return
+	}
+	log.Infof(">>> Exporting %s", configFile)
+
+	var original []string
+	if bytes, err := os.ReadFile(configFile); err == nil {
+		// an empty file has no lines, not a single empty one
+		if text := strings.TrimSuffix(string(bytes), "\n"); text != "" {
+			original = strings.Split(text, "\n")
+		}
+	} else if !os.IsNotExist(err) {
+		log.Warnf("Couldn't read %s: %s", configFile, err)
+		return
+	}
+
+	lines := mergeGtkRc20(original, gtkRc20Lines())
 
 	for _, l := range lines {
 		log.Debug(l)
@@ -813,6 +832,8 @@ func saveXsettingsd() {
 	lines = append(lines, fmt.Sprintf("Net/ThemeName \"%s\"", gsettings.gtkTheme))
 	lines = append(lines, fmt.Sprintf("Net/IconThemeName \"%s\"", gsettings.iconTheme))
 	lines = append(lines, fmt.Sprintf("Gtk/CursorThemeName \"%s\"", gsettings.cursorTheme))
+	lines = append(lines, fmt.Sprintf("Gtk/MonospaceFontName \"%s\"", gsettings.monospaceFontName))
+	lines = append(lines, fmt.Sprintf("Gtk/KeyThemeName \"%s\"", gsettings.keyTheme))
 
 	var v int
 	if gsettings.eventSounds {
@@ -828,6 +849,7 @@ func saveXsettingsd() {
 		v = 0
 	}
 	lines = append(lines, fmt.Sprintf("EnableInputFeedbackSounds %v", v))
+	lines = append(lines, fmt.Sprintf("Net/SoundThemeName \"%s\"", gsettings.soundTheme))
 
 	if gsettings.fontAntialiasing != "none" {
 		v = 1
@@ -858,6 +880,12 @@ func saveXsettingsd() {
 
 	lines = append(lines, fmt.Sprintf("Xft/RGBA \"%s\"", gsettings.fontRgbaOrder))
 
+	if gtkConfig.xftDpi > 0 {
+		lines = append(lines, fmt.Sprintf("Xft/DPI %v", gtkConfig.xftDpi))
+	}
+
+	lines = append(lines, fmt.Sprintf("Gtk/DecorationLayout \"%s\"", gtkDecorationLayout(gsettings.buttonLayout)))
+
 	for _, l := range lines {
 		log.Debug(l)
 	}
@@ -865,13 +893,16 @@ func saveXsettingsd() {
 	saveTextFile(lines, configFile)
 }
 
+// theme files exported to ~/.config; gtk-4.0/settings.ini may hold user settings, it's not a part of the theme
+var gtk4ThemeItems = []string{"gtk-4.0/gtk.css", "gtk-4.0/gtk-dark.css", "gtk-4.0/assets", "assets"}
+
 func linkGtk4Stuff() {
 	home := os.Getenv("HOME")
 	configPath := filepath.Join(home, ".config")
 	themeName := gsettings.gtkTheme
 
 	if gsettings.gtkTheme != "" {
-		log.Infof(">>> Symlinking files in %s", filepath.Join(configPath, "/gtk-4.0"))
+		log.Infof(">>> Exporting files to %s", filepath.Join(configPath, "/gtk-4.0"))
 		log.Debugf("GTK Theme: '%s' at '%s'", themeName, gtkThemePaths[themeName])
 		log.Debugf("Config path: '%s'", configPath)
 		themePath := gtkThemePaths[themeName]
@@ -887,49 +918,15 @@ func linkGtk4Stuff() {
 
 		clearGtk4Symlinks()
 
-		// Create symlinks
 		gtk4Dir := filepath.Join(configPath, "gtk-4.0")
 		if !pathExists(gtk4Dir) {
 			makeDir(gtk4Dir)
 		}
 
-		if pathExists(filepath.Join(themePath, "gtk-4.0/gtk.css")) {
-			cmd := exec.Command("ln", "-s", filepath.Join(themePath, "gtk-4.0/gtk.css"), filepath.Join(configPath, "gtk-4.0/gtk.css"))
-			err := cmd.Run()
-			if err != nil {
-				log.Warnf("Couldn't symlink '%s': %s", filepath.Join(themePath, "gtk-4.0/gtk.css"), err)
-			} else {
-				log.Debugf("Created symlink to '%s'", filepath.Join(themePath, "gtk-4.0/gtk.css"))
-			}
-		}
-
-		if pathExists(filepath.Join(themePath, "gtk-4.0/gtk-dark.css")) {
-			cmd := exec.Command("ln", "-s", filepath.Join(themePath, "gtk-4.0/gtk-dark.css"), filepath.Join(configPath, "gtk-4.0/gtk-dark.css"))
-			err := cmd.Run()
-			if err != nil {
-				log.Warnf("Couldn't symlink '%s': %s", filepath.Join(themePath, "gtk-4.0/gtk-dark.css"), err)
-			} else {
-				log.Debugf("Created symlink to '%s'", filepath.Join(themePath, "gtk-4.0/gtk-dark.css"))
-			}
-		}
-
-		if pathExists(filepath.Join(themePath, "gtk-4.0/assets")) {
-			cmd := exec.Command("ln", "-s", filepath.Join(themePath, "gtk-4.0/assets"), filepath.Join(configPath, "gtk-4.0/assets"))
-			err := cmd.Run()
-			if err != nil {
-				log.Warnf("Couldn't symlink '%s': %s", filepath.Join(themePath, "gtk-4.0/assets"), err)
-			} else {
-				log.Debugf("Created symlink to '%s'", filepath.Join(themePath, "gtk-4.0/assets"))
-			}
-		}
-
-		if pathExists(filepath.Join(themePath, "assets")) {
-			cmd := exec.Command("ln", "-s", filepath.Join(themePath, "assets"), filepath.Join(configPath, "assets"))
-			err := cmd.Run()
-			if err != nil {
-				log.Warnf("Couldn't symlink '%s': %s", filepath.Join(themePath, "assets"), err)
-			} else {
-				log.Debugf("Created symlink to '%s'", filepath.Join(themePath, "assets"))
+		for _, item := range gtk4ThemeItems {
+			src := filepath.Join(themePath, item)
+			if pathExists(src) {
+				exportGtk4Item(src, filepath.Join(configPath, item))
 			}
 		}
 
@@ -938,11 +935,25 @@ func linkGtk4Stuff() {
 	}
 }
 
+// exportGtk4Item symlinks the theme file or directory, or copies it if GTK4 flatpak apps are styled:
+// links to theme directories would be dangling inside the sandbox
+func exportGtk4Item(src, dst string) {
+	cmd := exec.Command("ln", "-s", src, dst)
+	if preferences.FlatpakGtk4Theme {
+		cmd = exec.Command("cp", "-rL", src, dst)
+	}
+	err := cmd.Run()
+	if err != nil {
+		log.Warnf("Couldn't export '%s': %s", src, err)
+	} else {
+		log.Debugf("Exported '%s' to '%s'", src, dst)
+	}
+}
+
 func clearGtk4Symlinks() {
 	home := os.Getenv("HOME")
 	configPath := filepath.Join(home, ".config")
-	items := []string{"gtk-4.0/gtk.css", "gtk-4.0/gtk-dark.css", "gtk-4.0/assets", "assets", "gtk-4.0/settings.ini"}
-	for _, item := range items {
+	for _, item := range gtk4ThemeItems {
 		p := filepath.Join(configPath, item)
 		if pathExists(p) {
 			log.Debugf("Removing '%s'", p)
@@ -966,16 +977,14 @@ func clearGtk4Symlinks() {
 func overrideFlatpakGTKTheme() {
 	theme := gsettings.gtkTheme
 	log.Infof("Overriding flatpak GTK theme to %s...", theme)
-	cmd := exec.Command("flatpak", "override", "--user", "--env", fmt.Sprintf("GTK_THEME=%s", theme))
-	if _, err := cmd.CombinedOutput(); err != nil {
+	if err := stylepak.SetOverrideEnv(nil, "", "GTK_THEME", theme); err != nil {
 		log.Warnf("failed to override flatpak gtk theme to %s: %s", theme, err)
 	}
 }
 
 func unsetFlatpakGTKTheme() {
 	log.Info("Removing Flatpak GTK Theme override...")
-	cmd := exec.Command("flatpak", "override", "--user", "--unset-env", "GTK_THEME")
-	if _, err := cmd.CombinedOutput(); err != nil {
+	if err := stylepak.UnsetOverrideEnv(nil, "", "GTK_THEME"); err != nil {
 		log.Warnf("failed to unset flatpak gtk theme override: %s", err)
 	}
 }
@@ -983,16 +992,14 @@ func unsetFlatpakGTKTheme() {
 func overrideFlatpakIconTheme() {
 	theme := gsettings.iconTheme
 	log.Infof("Overriding flatpak Icon theme to %s...", theme)
-	cmd := exec.Command("flatpak", "override", "--user", "--env", fmt.Sprintf("ICON_THEME=%s", theme))
-	if _, err := cmd.CombinedOutput(); err != nil {
+	if err := stylepak.SetOverrideEnv(nil, "", "ICON_THEME", theme); err != nil {
 		log.Warnf("failed to override flatpak icon theme to %s: %s", theme, err)
 	}
 }
 
 func unsetFlatpakIconTheme() {
 	log.Info("Removing Flatpak Icon Theme override...")
-	cmd := exec.Command("flatpak", "override", "--user", "--unset-env", "ICON_THEME")
-	if _, err := cmd.CombinedOutput(); err != nil {
+	if err := stylepak.UnsetOverrideEnv(nil, "", "ICON_THEME"); err != nil {
 		log.Warnf("failed to unset flatpak icon theme override: %s", err)
 	}
 }
@@ -1036,6 +1043,14 @@ func saveIndexTheme() {
 	}
 }
 
+// gtkThemePath returns the path of the GTK theme, looking the themes up if not done yet
+func gtkThemePath(name string) string {
+	if gtkThemePaths == nil {
+		_, gtkThemePaths = getThemeNames()
+	}
+	return gtkThemePaths[name]
+}
+
 func getThemeNames() ([]string, map[string]string) {
 	var dirs []string
 	themePaths := make(map[string]string) // theme name 2 theme path
@@ -1054,6 +1069,7 @@ func getThemeNames() ([]string, map[string]string) {
 		}
 	}
 
+	// key themes, see getKeyThemeNames
 	exclusions := []string{"Default", "Emacs"}
 	var names []string
 	for _, d := range dirs {
@@ -1089,6 +1105,28 @@ func getThemeNames() ([]string, map[string]string) {
 	return names, themePaths
 }
 
+// getKeyThemeNames returns names of themes that provide gtk-3.0/gtk-keys.css, "Default" first
+func getKeyThemeNames() []string {
+	names := []string{"Default"}
+	var others []string
+	for _, d := range allThemeDirs(themeKindGtk) {
+		files, err := listFiles(d)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if f.IsDir() && !isIn(names, f.Name()) && !isIn(others, f.Name()) &&
+				pathExists(filepath.Join(d, f.Name(), "gtk-3.0/gtk-keys.css")) {
+				others = append(others, f.Name())
+				log.Debugf("Key theme found: '%s' at '%s'", f.Name(), filepath.Join(d, f.Name()))
+			}
+		}
+	}
+	sort.Strings(others)
+
+	return append(names, others...)
+}
+
 // returns map[displayName]folderName
 func getIconThemeNames() map[string]string {
 	var dirs []string
@@ -1334,7 +1372,7 @@ func getWindow(b *gtk.Builder, id string) (*gtk.Window, error) {
 
 	window, ok := obj.(*gtk.Window)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("object %q is not a GtkWindow", id)
 	}
 	return window, nil
 }
@@ -1347,7 +1385,7 @@ func getScrolledWindow(b *gtk.Builder, id string) (*gtk.ScrolledWindow, error) {
 
 	window, ok := obj.(*gtk.ScrolledWindow)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("object %q is not a GtkScrolledWindow", id)
 	}
 	return window, nil
 }
@@ -1359,11 +1397,23 @@ func getViewPort(b *gtk.Builder, id string) (*gtk.Viewport, error) {
 	}
 	viewport, ok := obj.(*gtk.Viewport)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("object %q is not a GtkViewport", id)
 	}
 	return viewport, nil
 }
 
+func getBox(b *gtk.Builder, id string) (*gtk.Box, error) {
+	obj, err := b.GetObject(id)
+	if err != nil {
+		return nil, err
+	}
+	box, ok := obj.(*gtk.Box)
+	if !ok {
+		return nil, fmt.Errorf("object %q is not a GtkBox", id)
+	}
+	return box, nil
+}
+
 func getButton(b *gtk.Builder, id string) (*gtk.Button, error) {
 	obj, err := b.GetObject(id)
 	if err != nil {
@@ -1371,7 +1421,7 @@ func getButton(b *gtk.Builder, id string) (*gtk.Button, error) {
 	}
 	btn, ok := obj.(*gtk.Button)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("object %q is not a GtkButton", id)
 	}
 	return btn, nil
 }
@@ -1383,7 +1433,7 @@ func getGrid(b *gtk.Builder, id string) (*gtk.Grid, error) {
 	}
 	grid, ok := obj.(*gtk.Grid)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("object %q is not a GtkGrid", id)
 	}
 	return grid, nil
 }
@@ -1395,7 +1445,7 @@ func getLabel(b *gtk.Builder, id string) (*gtk.Label, error) {
 	}
 	label, ok := obj.(*gtk.Label)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("object %q is not a GtkLabel", id)
 	}
 	return label, nil
 }
@@ -1407,7 +1457,7 @@ func getMenuBar(b *gtk.Builder, id string) (*gtk.MenuBar, error) {
 	}
 	menuBar, ok := obj.(*gtk.MenuBar)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("object %q is not a GtkMenuBar", id)
 	}
 	return menuBar, nil
 }
@@ -1419,7 +1469,7 @@ func getMenuItem(b *gtk.Builder, id string) (*gtk.MenuItem, error) {
 	}
 	item, ok := obj.(*gtk.MenuItem)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("object %q is not a GtkMenuItem", id)
 	}
 	return item, nil
 }
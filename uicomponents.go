@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"html"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/gotk3/gotk3/gdk"
@@ -19,6 +21,8 @@ func setUpThemeListBox(currentTheme string) *gtk.ListBox {
 
 	themeNames, themePaths := getThemeNames()
 	gtkThemePaths = themePaths
+	var entries []themeListEntry
+	var jobs []thumbnailJob
 
 	for _, name := range themeNames {
 		row, _ := gtk.ListBoxRowNew()
@@ -31,9 +35,13 @@ func setUpThemeListBox(currentTheme string) *gtk.ListBox {
 		lbl.SetProperty("margin-start", 6)
 		lbl.SetProperty("margin-end", 6)
 		n := name
-		eventBox.Connect("button-press-event", func() {
+		eventBox.Connect("button-press-event", func(_ *gtk.EventBox, event *gdk.Event) bool {
+			if isSecondaryButton(event) {
+				return showThemeContextMenu(themeKindGtk, n, event)
+			}
 			gtkSettings.SetProperty("gtk-theme-name", n)
 			gsettings.gtkTheme = n
+			return false
 		})
 		row.Connect("focus-in-event", func() {
 			gtkSettings.SetProperty("gtk-theme-name", n)
@@ -43,7 +51,19 @@ func setUpThemeListBox(currentTheme string) *gtk.ListBox {
 			rowToSelect = row
 		}
 
+		img := newThumbnailImage(themeKindGtk)
+		box.PackStart(img, false, false, 0)
+		if source := thumbnailSource(themeKindGtk, themePaths[name]); source != "" {
+			jobs = append(jobs, thumbnailJob{kind: themeKindGtk, source: source, img: img})
+		}
+
 		box.PackStart(lbl, false, false, 0)
+		support := getGtkThemeSupport(name, themePaths[name])
+		addThemeSupportBadges(box, support)
+
+		entry := newThemeListEntry(themeKindGtk, name, name)
+		entry.support = support
+		entries = append(entries, entry)
 
 		row.Add(eventBox)
 		listBox.Add(row)
@@ -52,10 +72,58 @@ func setUpThemeListBox(currentTheme string) *gtk.ListBox {
 		listBox.SelectRow(rowToSelect)
 		rowToFocus = rowToSelect
 	}
+	setUpThemeListFilter(listBox, themeKindGtk, entries)
+	loadThumbnails(jobs)
 
 	return listBox
 }
 
+func addThemeSupportBadges(box *gtk.Box, support gtkThemeSupport) {
+	// packed from the right edge of the row
+	badges := []struct {
+		text    string
+		tooltip string
+		present bool
+	}{
+		{voc["dark"], "gtk-dark.css", support.dark},
+		{"GTK4", "gtk-4.0", support.gtk4},
+		{"GTK3", "gtk-3.0", support.gtk3},
+		{"GTK2", "gtk-2.0", support.gtk2},
+	}
+	for _, b := range badges {
+		if !b.present {
+			continue
+		}
+		lbl, _ := gtk.LabelNew("")
+		lbl.SetMarkup(fmt.Sprintf("<small>%s</small>", b.text))
+		lbl.SetTooltipText(b.tooltip)
+		styleContext, _ := lbl.GetStyleContext()
+		styleContext.AddClass("dim-label")
+		box.PackEnd(lbl, false, false, 0)
+	}
+}
+
+// confirmThemeSupport warns about toolkits that the selected theme won't style, once per theme,
+// and returns false if the user cancels applying settings. Confirmed themes are kept in preferences.
+func confirmThemeSupport() bool {
+	lines, allThemed := themeSupportSummary(gsettings.gtkTheme)
+	if allThemed || isIn(preferences.ThemeSupportConfirmed, gsettings.gtkTheme) {
+		return true
+	}
+
+	dialog := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL, gtk.MESSAGE_WARNING, gtk.BUTTONS_OK_CANCEL, "%s: %s", voc["toolkit-support"], gsettings.gtkTheme)
+	dialog.FormatSecondaryText("%s\n\n%s", strings.Join(lines, "\n"), voc["apply-anyway"])
+	response := dialog.Run()
+	dialog.Destroy()
+
+	if response == gtk.RESPONSE_OK {
+		preferences.ThemeSupportConfirmed = append(preferences.ThemeSupportConfirmed, gsettings.gtkTheme)
+		savePreferences()
+		return true
+	}
+	return false
+}
+
 func setUpIconThemeListBox(currentIconTheme string) *gtk.ListBox {
 	listBox, _ := gtk.ListBoxNew()
 	var rowToSelect *gtk.ListBoxRow
@@ -70,6 +138,8 @@ func setUpIconThemeListBox(currentIconTheme string) *gtk.ListBox {
 		return strings.ToUpper(displayNames[i]) < strings.ToUpper(displayNames[j])
 	})
 
+	var entries []themeListEntry
+	var jobs []thumbnailJob
 	for _, name := range displayNames {
 		row, _ := gtk.ListBoxRowNew()
 
@@ -81,9 +151,13 @@ func setUpIconThemeListBox(currentIconTheme string) *gtk.ListBox {
 		lbl.SetProperty("margin-start", 6)
 		lbl.SetProperty("margin-end", 6)
 		n := name
-		eventBox.Connect("button-press-event", func() {
+		eventBox.Connect("button-press-event", func(_ *gtk.EventBox, event *gdk.Event) bool {
+			if isSecondaryButton(event) {
+				return showThemeContextMenu(themeKindIcon, namesMap[n], event)
+			}
 			gtkSettings.SetProperty("gtk-icon-theme-name", namesMap[n])
 			gsettings.iconTheme = namesMap[n]
+			return false
 		})
 		row.Connect("focus-in-event", func() {
 			gtkSettings.SetProperty("gtk-icon-theme-name", namesMap[n])
@@ -94,7 +168,14 @@ func setUpIconThemeListBox(currentIconTheme string) *gtk.ListBox {
 			rowToSelect = row
 		}
 
+		img := newThumbnailImage(themeKindIcon)
+		box.PackStart(img, false, false, 0)
+		if source := thumbnailSource(themeKindIcon, themeFolderPath(themeKindIcon, namesMap[name])); source != "" {
+			jobs = append(jobs, thumbnailJob{kind: themeKindIcon, source: source, img: img})
+		}
+
 		box.PackStart(lbl, false, false, 0)
+		entries = append(entries, newThemeListEntry(themeKindIcon, name, namesMap[name]))
 
 		row.Add(eventBox)
 		listBox.Add(row)
@@ -103,6 +184,8 @@ func setUpIconThemeListBox(currentIconTheme string) *gtk.ListBox {
 		listBox.SelectRow(rowToSelect)
 		rowToFocus = rowToSelect
 	}
+	setUpThemeListFilter(listBox, themeKindIcon, entries)
+	loadThumbnails(jobs)
 
 	return listBox
 }
@@ -119,6 +202,8 @@ func setUpCursorThemeListBox(currentCursorTheme string) *gtk.ListBox {
 		return strings.ToUpper(names[i]) < strings.ToUpper(names[j])
 	})
 
+	var entries []themeListEntry
+	var jobs []thumbnailJob
 	for _, name := range names {
 		row, _ := gtk.ListBoxRowNew()
 
@@ -130,10 +215,14 @@ func setUpCursorThemeListBox(currentCursorTheme string) *gtk.ListBox {
 		lbl.SetProperty("margin-start", 6)
 		lbl.SetProperty("margin-end", 6)
 		n := name
-		eventBox.Connect("button-press-event", func() {
+		eventBox.Connect("button-press-event", func(_ *gtk.EventBox, event *gdk.Event) bool {
+			if isSecondaryButton(event) {
+				return showThemeContextMenu(themeKindCursor, cursorThemeNames[n], event)
+			}
 			gtkSettings.SetProperty("gtk-cursor-theme-name", cursorThemeNames[n])
 			gsettings.cursorTheme = cursorThemeNames[n]
 			displayCursorThemes()
+			return false
 		})
 		row.Connect("focus-in-event", func() {
 			gtkSettings.SetProperty("gtk-cursor-theme-name", cursorThemeNames[n])
@@ -143,7 +232,16 @@ func setUpCursorThemeListBox(currentCursorTheme string) *gtk.ListBox {
 			rowToSelect = row
 		}
 
+		img := newThumbnailImage(themeKindCursor)
+		box.PackStart(img, false, false, 0)
+		if cursorsPath, ok := cursorThemes[cursorThemeNames[name]]; ok {
+			if source := thumbnailSource(themeKindCursor, filepath.Dir(cursorsPath)); source != "" {
+				jobs = append(jobs, thumbnailJob{kind: themeKindCursor, source: source, img: img})
+			}
+		}
+
 		box.PackStart(lbl, false, false, 0)
+		entries = append(entries, newThemeListEntry(themeKindCursor, name, cursorThemeNames[name]))
 
 		row.Add(eventBox)
 		listBox.Add(row)
@@ -152,84 +250,119 @@ func setUpCursorThemeListBox(currentCursorTheme string) *gtk.ListBox {
 		listBox.SelectRow(rowToSelect)
 		rowToFocus = rowToSelect
 	}
+	setUpThemeListFilter(listBox, themeKindCursor, entries)
+	loadThumbnails(jobs)
 
 	return listBox
 }
 
-func setUpWidgetsPreview() *gtk.Frame {
-	frame, _ := gtk.FrameNew(fmt.Sprintf("  %s  ", voc["widget-style-preview"]))
-	frame.SetLabelAlign(0.5, 0.5)
-	frame.SetProperty("margin", 6)
-	frame.SetProperty("valign", gtk.ALIGN_START)
+func isSecondaryButton(event *gdk.Event) bool {
+	btn := gdk.EventButtonNewFromEvent(event)
+	return btn.Button() == gdk.BUTTON_SECONDARY
+}
 
-	grid, _ := gtk.GridNew()
-	grid.SetRowSpacing(6)
-	grid.SetColumnSpacing(12)
-	grid.SetProperty("margin", 6)
-	frame.Add(grid)
+// showThemeContextMenu pops up actions for themes installed in user-writable locations
+func showThemeContextMenu(kind, folderName string, event *gdk.Event) bool {
+	path := userThemePath(kind, folderName)
+	if path == "" {
+		return false
+	}
 
-	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
-	box.SetProperty("hexpand", true)
-	grid.Attach(box, 0, 0, 3, 1)
+	menu, _ := gtk.MenuNew()
+
+	item, _ := gtk.MenuItemNewWithLabel(voc["remove"])
+	item.Connect("activate", func() {
+		removeThemeWithConfirmation(kind, folderName)
+	})
+	menu.Append(item)
+
+	item, _ = gtk.MenuItemNewWithLabel(voc["open-folder"])
+	item.Connect("activate", func() {
+		cmd := exec.Command("xdg-open", path)
+		if err := cmd.Start(); err != nil {
+			log.Warnf("Couldn't open '%s': %s", path, err)
+			return
+		}
+		go cmd.Wait()
+	})
+	menu.Append(item)
+
+	item, _ = gtk.MenuItemNewWithLabel(voc["show-details"])
+	item.Connect("activate", func() {
+		dialog := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL, gtk.MESSAGE_INFO, gtk.BUTTONS_CLOSE, "%s", folderName)
+		dialog.FormatSecondaryText("%s", strings.Join(themeDetails(kind, path), "\n"))
+		dialog.Run()
+		dialog.Destroy()
+	})
+	menu.Append(item)
 
-	btn, _ := gtk.ButtonNewFromIconName("go-previous", gtk.ICON_SIZE_BUTTON)
-	btn.SetProperty("can-focus", false)
-	box.PackStart(btn, false, false, 0)
+	menu.ShowAll()
+	menu.PopupAtPointer(event)
 
-	btn, _ = gtk.ButtonNewFromIconName("go-next", gtk.ICON_SIZE_BUTTON)
-	btn.SetProperty("can-focus", false)
-	box.PackStart(btn, false, false, 0)
+	return true
+}
 
-	btn, _ = gtk.ButtonNewFromIconName("process-stop", gtk.ICON_SIZE_BUTTON)
-	btn.SetProperty("can-focus", false)
-	box.PackStart(btn, false, false, 0)
+func removeThemeWithConfirmation(kind, folderName string) {
+	if err := checkThemeRemovable(kind, folderName); err != nil {
+		dialog := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "%s", voc["cannot-remove-theme"])
+		dialog.FormatSecondaryText("%s", err)
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
 
-	entry, _ := gtk.EntryNew()
-	entry.SetProperty("can-focus", false)
-	box.PackStart(entry, true, true, 0)
-
-	checkButton, _ := gtk.CheckButtonNew()
-	checkButton.SetProperty("can-focus", false)
-	checkButton.SetLabel(voc["check-button"])
-	grid.Attach(checkButton, 0, 1, 1, 1)
-
-	radioButton, _ := gtk.RadioButtonNew(nil)
-	radioButton.SetProperty("can-focus", false)
-	radioButton.SetLabel(voc["radio-button"])
-	grid.Attach(radioButton, 0, 2, 1, 1)
-
-	spinButton, _ := gtk.SpinButtonNewWithRange(0, 1000, 10)
-	spinButton.SetProperty("can-focus", false)
-	grid.Attach(spinButton, 0, 3, 1, 1)
-
-	button, _ := gtk.ButtonNewFromIconName("search", gtk.ICON_SIZE_BUTTON)
-	button.SetProperty("can-focus", false)
-	button.SetLabel(voc["button"])
-	grid.Attach(button, 1, 3, 1, 1)
-
-	scale, _ := gtk.ScaleNewWithRange(gtk.ORIENTATION_HORIZONTAL, 0, 100, 1)
-	scale.SetProperty("can-focus", false)
-	scale.SetDrawValue(true)
-	scale.SetValue(50)
-	grid.Attach(scale, 1, 1, 2, 1)
-
-	separator, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
-	separator.SetProperty("can-focus", false)
-	separator.SetProperty("valign", gtk.ALIGN_CENTER)
-	grid.Attach(separator, 1, 2, 2, 1)
+	dialog := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, "%s '%s'?", voc["remove-theme"], folderName)
+	dialog.FormatSecondaryText("%s", strings.Join(userThemePaths(kind, folderName), "\n"))
+	response := dialog.Run()
+	dialog.Destroy()
+	if response != gtk.RESPONSE_YES {
+		return
+	}
 
-	combo, _ := gtk.ComboBoxTextNew()
-	combo.Append("entry #1", fmt.Sprintf("%s 1", voc["entry"]))
-	combo.Append("entry #2", fmt.Sprintf("%s 2", voc["entry"]))
-	combo.SetProperty("can-focus", false)
-	grid.Attach(combo, 2, 3, 1, 1)
+	if err := removeUserTheme(kind, folderName); err != nil {
+		log.Warn(err)
+		dialog := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "%s", voc["cannot-remove-theme"])
+		dialog.FormatSecondaryText("%s", err)
+		dialog.Run()
+		dialog.Destroy()
+	}
+
+	switch kind {
+	case themeKindGtk:
+		displayThemes()
+	case themeKindIcon:
+		cursorThemes, cursorThemeNames = getCursorThemes()
+		displayIconThemes()
+	case themeKindCursor:
+		cursorThemes, cursorThemeNames = getCursorThemes()
+		displayCursorThemes()
+	}
+}
+
+func setUpWidgetsPreview() *gtk.Frame {
+	frame, _ := gtk.FrameNew(fmt.Sprintf("  %s  ", voc["widget-style-preview"]))
+	frame.SetLabelAlign(0.5, 0.5)
+	frame.SetProperty("margin", 6)
+	frame.SetProperty("valign", gtk.ALIGN_START)
 
-	progressBar, _ := gtk.ProgressBarNew()
-	progressBar.SetFraction(0.3)
-	progressBar.SetText("30%")
-	progressBar.SetShowText(true)
-	progressBar.SetProperty("margin-bottom", 6)
-	grid.Attach(progressBar, 0, 4, 3, 1)
+	notebook, _ := gtk.NotebookNew()
+	notebook.SetProperty("can-focus", false)
+	notebook.SetProperty("margin", 6)
+	frame.Add(notebook)
+
+	tabs := []struct {
+		page  gtk.IWidget
+		label string
+	}{
+		{setUpGalleryControls(), voc["gallery-controls"]},
+		{setUpGalleryRanges(), voc["gallery-ranges"]},
+		{setUpGalleryLists(), voc["gallery-lists"]},
+		{setUpGalleryPopups(), voc["gallery-popups"]},
+	}
+	for _, t := range tabs {
+		label, _ := gtk.LabelNew(t.label)
+		notebook.AppendPage(t.page, label)
+	}
 
 	return frame
 }
@@ -276,6 +409,27 @@ func setUpThemeSettingsForm(defaultFontName string) *gtk.Grid {
 	})
 	grid.Attach(combo, 1, 1, 1, 1)
 
+	label, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["key-theme"]))
+	label.SetProperty("halign", gtk.ALIGN_END)
+	grid.Attach(label, 0, 2, 1, 1)
+
+	comboKeyTheme, _ := gtk.ComboBoxTextNew()
+	comboKeyTheme.SetTooltipText(voc["key-theme-tooltip"])
+	keyThemes := getKeyThemeNames()
+	if !isIn(keyThemes, gsettings.keyTheme) {
+		keyThemes = append(keyThemes, gsettings.keyTheme)
+	}
+	for _, name := range keyThemes {
+		comboKeyTheme.Append(name, name)
+	}
+	comboKeyTheme.SetActiveID(gsettings.keyTheme)
+	comboKeyTheme.SetProperty("can-focus", false)
+	comboKeyTheme.Connect("changed", func() {
+		gsettings.keyTheme = comboKeyTheme.GetActiveID()
+		gtkSettings.SetProperty("gtk-key-theme-name", gsettings.keyTheme)
+	})
+	grid.Attach(comboKeyTheme, 1, 2, 1, 1)
+
 	return grid
 }
 
@@ -516,6 +670,77 @@ func setUpFontSettingsForm() *gtk.Frame {
 	})
 	g.Attach(sb, 1, 3, 1, 1)
 
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["monospace-font"]))
+	lbl.SetProperty("halign", gtk.ALIGN_END)
+	g.Attach(lbl, 0, 4, 1, 1)
+
+	fbMonospace, _ := gtk.FontButtonNew()
+	fbMonospace.SetFont(gsettings.monospaceFontName)
+	fbMonospace.Connect("font-set", func() {
+		gsettings.monospaceFontName = fbMonospace.GetFont()
+	})
+	g.Attach(fbMonospace, 1, 4, 1, 1)
+
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["document-font"]))
+	lbl.SetProperty("halign", gtk.ALIGN_END)
+	g.Attach(lbl, 0, 5, 1, 1)
+
+	fbDocument, _ := gtk.FontButtonNew()
+	fbDocument.SetFont(gsettings.documentFontName)
+	fbDocument.Connect("font-set", func() {
+		gsettings.documentFontName = fbDocument.GetFont()
+	})
+	g.Attach(fbDocument, 1, 5, 1, 1)
+
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["titlebar-font"]))
+	lbl.SetProperty("halign", gtk.ALIGN_END)
+	g.Attach(lbl, 0, 6, 1, 1)
+
+	fbTitlebar, _ := gtk.FontButtonNew()
+	fbTitlebar.SetFont(gsettings.titlebarFont)
+	fbTitlebar.Connect("font-set", func() {
+		gsettings.titlebarFont = fbTitlebar.GetFont()
+	})
+	g.Attach(fbTitlebar, 1, 6, 1, 1)
+
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["xft-dpi"]))
+	lbl.SetProperty("halign", gtk.ALIGN_END)
+	g.Attach(lbl, 0, 7, 1, 1)
+
+	sbDpi, _ := gtk.SpinButtonNewWithRange(0, 480, 1)
+	sbDpi.SetTooltipText(voc["xft-dpi-tooltip"])
+	if gtkConfig.xftDpi > 0 {
+		sbDpi.SetValue(float64(gtkConfig.xftDpi / 1024))
+	}
+	sbDpi.Connect("value-changed", func() {
+		dpi := sbDpi.GetValueAsInt()
+		if dpi > 0 {
+			gtkConfig.xftDpi = dpi * 1024
+		} else {
+			gtkConfig.xftDpi = -1
+		}
+	})
+	g.Attach(sbDpi, 1, 7, 1, 1)
+
+	lbl, _ = gtk.LabelNew("")
+	lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["rendering-preview"]))
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	g.Attach(lbl, 0, 8, 2, 1)
+
+	renderingPreview, redrawPreview := setUpFontRenderingPreview(func(r fontRendering) {
+		comboHinting.SetActiveID(r.hinting)
+		comboAntialiasing.SetActiveID(r.antialiasing)
+		if r.antialiasing == "rgba" {
+			comboRgba.SetActiveID(r.rgbaOrder)
+		}
+	})
+	g.Attach(renderingPreview, 0, 9, 2, 1)
+
+	comboHinting.Connect("changed", redrawPreview)
+	comboAntialiasing.Connect("changed", redrawPreview)
+	comboRgba.Connect("changed", redrawPreview)
+	sb.Connect("value-changed", redrawPreview)
+
 	return frame
 }
 
@@ -625,9 +850,408 @@ func setUpOtherSettingsForm() *gtk.Frame {
 	})
 	g.Attach(cbInputSounds, 0, 7, 2, 1)
 
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["sound-theme"]))
+	lbl.SetProperty("halign", gtk.ALIGN_END)
+	g.Attach(lbl, 0, 8, 1, 1)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	g.Attach(box, 1, 8, 1, 1)
+
+	comboSoundTheme, _ := gtk.ComboBoxTextNew()
+	soundThemes := getSoundThemes()
+	if _, ok := soundThemes[gsettings.soundTheme]; !ok {
+		soundThemes[gsettings.soundTheme] = gsettings.soundTheme
+	}
+	for _, id := range sortedSoundThemeIDs(soundThemes) {
+		comboSoundTheme.Append(id, soundThemes[id])
+	}
+	comboSoundTheme.SetActiveID(gsettings.soundTheme)
+	comboSoundTheme.Connect("changed", func() {
+		gsettings.soundTheme = comboSoundTheme.GetActiveID()
+		gtkConfig.soundThemeName = gsettings.soundTheme
+	})
+	box.PackStart(comboSoundTheme, true, true, 0)
+
+	if canberraAvailable() {
+		btnPlay, _ := gtk.ButtonNewFromIconName("media-playback-start-symbolic", gtk.ICON_SIZE_BUTTON)
+		btnPlay.SetTooltipText(voc["play-sample"])
+		btnPlay.Connect("clicked", func() {
+			playSoundThemeSample(comboSoundTheme.GetActiveID())
+		})
+		box.PackStart(btnPlay, false, false, 0)
+	}
+
+	return frame
+}
+
+func setUpWindowSettingsForm() *gtk.Frame {
+	frame, _ := gtk.FrameNew(fmt.Sprintf("  %s  ", voc["window-settings"]))
+	frame.SetLabelAlign(0.5, 0.5)
+	frame.SetProperty("margin", 6)
+	g, _ := gtk.GridNew()
+	g.SetRowSpacing(12)
+	g.SetColumnSpacing(12)
+	g.SetProperty("margin", 6)
+	g.SetProperty("hexpand", true)
+	g.SetProperty("vexpand", true)
+	frame.Add(g)
+
+	lbl, _ := gtk.LabelNew("")
+	lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["titlebar-buttons"]))
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	g.Attach(lbl, 0, 0, 2, 1)
+
+	headerBar, _ := gtk.HeaderBarNew()
+	headerBar.SetTitle("nwg-look")
+	headerBar.SetShowCloseButton(true)
+	headerBar.SetDecorationLayout(gtkDecorationLayout(gsettings.buttonLayout))
+
+	editor := setUpButtonLayoutEditor(gsettings.buttonLayout, func(layout string) {
+		gsettings.buttonLayout = layout
+		gtkConfig.decorationLayout = layout
+		headerBar.SetDecorationLayout(gtkDecorationLayout(layout))
+	})
+	g.Attach(editor, 0, 1, 2, 1)
+	g.Attach(headerBar, 0, 2, 2, 1)
+
+	lbl, _ = gtk.LabelNew("")
+	lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["titlebar-actions"]))
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	g.Attach(lbl, 0, 3, 2, 1)
+
+	actions := []struct {
+		label  string
+		action *string
+	}{
+		{voc["double-click"], &gsettings.actionDoubleClickTitlebar},
+		{voc["middle-click"], &gsettings.actionMiddleClickTitlebar},
+		{voc["right-click"], &gsettings.actionRightClickTitlebar},
+	}
+	for i, a := range actions {
+		action := a.action
+		lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", a.label))
+		lbl.SetProperty("halign", gtk.ALIGN_END)
+		g.Attach(lbl, 0, 4+i, 1, 1)
+
+		combo, _ := gtk.ComboBoxTextNew()
+		for _, id := range titlebarActions {
+			combo.Append(id, voc[fmt.Sprintf("titlebar-action-%s", id)])
+		}
+		combo.SetActiveID(*action)
+		combo.Connect("changed", func() {
+			*action = combo.GetActiveID()
+		})
+		g.Attach(combo, 1, 4+i, 1, 1)
+	}
+
 	return frame
 }
 
+func setUpAccessibilitySettingsForm() *gtk.Frame {
+	// We won't be applying these properties to gtk.Settings for preview,
+	// as they remain unchanged in once open window.
+
+	frame, _ := gtk.FrameNew(fmt.Sprintf("  %s  ", voc["accessibility-settings"]))
+	frame.SetLabelAlign(0.5, 0.5)
+	frame.SetProperty("margin", 6)
+	g, _ := gtk.GridNew()
+	g.SetRowSpacing(12)
+	g.SetColumnSpacing(12)
+	g.SetProperty("margin", 6)
+	g.SetProperty("hexpand", true)
+	g.SetProperty("vexpand", true)
+	frame.Add(g)
+
+	var row int
+
+	lbl, _ := gtk.LabelNew("")
+	lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["seeing"]))
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	g.Attach(lbl, 0, row, 2, 1)
+	row++
+
+	cbHighContrast, _ := gtk.CheckButtonNewWithLabel(voc["high-contrast"])
+	cbHighContrast.SetActive(gsettings.highContrast)
+	cbHighContrast.Connect("toggled", func() {
+		gsettings.highContrast = cbHighContrast.GetActive()
+	})
+	g.Attach(cbHighContrast, 0, row, 2, 1)
+	row++
+
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["text-size"]))
+	lbl.SetProperty("halign", gtk.ALIGN_END)
+	g.Attach(lbl, 0, row, 1, 1)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	g.Attach(box, 1, row, 1, 1)
+
+	sbScaling, _ := gtk.SpinButtonNewWithRange(0.5, 3, 0.01)
+	sbScaling.SetValue(gsettings.textScalingFactor)
+	sbScaling.SetTooltipText(voc["text-scaling-factor"])
+	sbScaling.Connect("value-changed", func() {
+		gsettings.textScalingFactor = sbScaling.GetValue()
+	})
+
+	presets := []struct {
+		label  string
+		factor float64
+	}{
+		{voc["default"], 1.0},
+		{voc["large"], 1.25},
+		{voc["larger"], 1.5},
+		{voc["largest"], 2.0},
+	}
+	for _, p := range presets {
+		factor := p.factor
+		btn, _ := gtk.ButtonNewWithLabel(p.label)
+		btn.SetTooltipText(fmt.Sprintf("%s: %.2f", voc["text-scaling-factor"], factor))
+		btn.Connect("clicked", func() {
+			sbScaling.SetValue(factor)
+		})
+		box.PackStart(btn, false, false, 0)
+	}
+	box.PackStart(sbScaling, false, false, 6)
+	row++
+
+	lbl, _ = gtk.LabelNew("")
+	lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["motion"]))
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	g.Attach(lbl, 0, row, 2, 1)
+	row++
+
+	cbAnimations, _ := gtk.CheckButtonNewWithLabel(voc["enable-animations"])
+	cbAnimations.SetActive(gsettings.enableAnimations)
+	cbAnimations.Connect("toggled", func() {
+		gsettings.enableAnimations = cbAnimations.GetActive()
+	})
+	g.Attach(cbAnimations, 0, row, 2, 1)
+	row++
+
+	cbOverlayScrolling, _ := gtk.CheckButtonNewWithLabel(voc["overlay-scrolling"])
+	cbOverlayScrolling.SetActive(gsettings.overlayScrolling)
+	cbOverlayScrolling.Connect("toggled", func() {
+		gsettings.overlayScrolling = cbOverlayScrolling.GetActive()
+	})
+	g.Attach(cbOverlayScrolling, 0, row, 2, 1)
+	row++
+
+	lbl, _ = gtk.LabelNew("")
+	lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["typing"]))
+	lbl.SetProperty("halign", gtk.ALIGN_START)
+	g.Attach(lbl, 0, row, 2, 1)
+	row++
+
+	sbBlinkTime, _ := gtk.SpinButtonNewWithRange(100, 2500, 10)
+	sbBlinkTimeout, _ := gtk.SpinButtonNewWithRange(1, 2147483647, 1)
+
+	cbCursorBlink, _ := gtk.CheckButtonNewWithLabel(voc["cursor-blink"])
+	cbCursorBlink.SetActive(gsettings.cursorBlink)
+	cbCursorBlink.Connect("toggled", func() {
+		gsettings.cursorBlink = cbCursorBlink.GetActive()
+		sbBlinkTime.SetSensitive(gsettings.cursorBlink)
+		sbBlinkTimeout.SetSensitive(gsettings.cursorBlink)
+	})
+	g.Attach(cbCursorBlink, 0, row, 2, 1)
+	row++
+
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["cursor-blink-time"]))
+	lbl.SetProperty("halign", gtk.ALIGN_END)
+	g.Attach(lbl, 0, row, 1, 1)
+
+	sbBlinkTime.SetValue(float64(gsettings.cursorBlinkTime))
+	sbBlinkTime.SetSensitive(gsettings.cursorBlink)
+	sbBlinkTime.Connect("value-changed", func() {
+		gsettings.cursorBlinkTime = int(sbBlinkTime.GetValue())
+	})
+	g.Attach(sbBlinkTime, 1, row, 1, 1)
+	row++
+
+	lbl, _ = gtk.LabelNew(fmt.Sprintf("%s:", voc["cursor-blink-timeout"]))
+	lbl.SetProperty("halign", gtk.ALIGN_END)
+	g.Attach(lbl, 0, row, 1, 1)
+
+	sbBlinkTimeout.SetValue(float64(gsettings.cursorBlinkTimeout))
+	sbBlinkTimeout.SetSensitive(gsettings.cursorBlink)
+	sbBlinkTimeout.Connect("value-changed", func() {
+		gsettings.cursorBlinkTimeout = int(sbBlinkTimeout.GetValue())
+	})
+	g.Attach(sbBlinkTimeout, 1, row, 1, 1)
+
+	return frame
+}
+
+func setUpAdvancedSettingsForm() *gtk.Frame {
+	frame, _ := gtk.FrameNew(fmt.Sprintf("  %s  ", voc["advanced-settings"]))
+	frame.SetLabelAlign(0.5, 0.5)
+	frame.SetProperty("margin", 6)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetProperty("margin", 6)
+	box.SetProperty("hexpand", true)
+	box.SetProperty("vexpand", true)
+	frame.Add(box)
+
+	schemas := loadGSchemas(advancedSchemas)
+	if len(schemas) == 0 {
+		lbl, _ := gtk.LabelNew(voc["no-schemas-found"])
+		box.PackStart(lbl, true, true, 0)
+		return frame
+	}
+
+	combo, _ := gtk.ComboBoxTextNew()
+	combo.SetProperty("halign", gtk.ALIGN_START)
+	for _, id := range advancedSchemas {
+		if _, ok := schemas[id]; ok {
+			combo.Append(id, id)
+		}
+	}
+	box.PackStart(combo, false, false, 0)
+
+	sw, _ := gtk.ScrolledWindowNew(nil, nil)
+	sw.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	box.PackStart(sw, true, true, 0)
+	vp, _ := gtk.ViewportNew(nil, nil)
+	sw.Add(vp)
+
+	var keysGrid *gtk.Grid
+	combo.Connect("changed", func() {
+		if keysGrid != nil {
+			keysGrid.Destroy()
+		}
+		keysGrid = setUpSchemaKeysGrid(schemas[combo.GetActiveID()])
+		vp.Add(keysGrid)
+		vp.ShowAll()
+	})
+	combo.SetActive(0)
+
+	return frame
+}
+
+func setUpSchemaKeysGrid(schema gschema) *gtk.Grid {
+	g, _ := gtk.GridNew()
+	g.SetRowSpacing(12)
+	g.SetColumnSpacing(12)
+	g.SetProperty("margin", 6)
+
+	values, err := getGsettingsSchemaValues(schema.ID)
+	if err != nil {
+		log.Warnf("Couldn't read %s: %s", schema.ID, err)
+	}
+
+	for row, key := range schema.Keys {
+		k := key
+		current, ok := gsettingsAdvanced[schema.ID][k.Name]
+		if !ok {
+			current = values[k.Name]
+		}
+
+		lbl, _ := gtk.LabelNew("")
+		lbl.SetMarkup(fmt.Sprintf("<b>%s</b>\n<small>%s</small>", html.EscapeString(k.Name), html.EscapeString(k.Summary)))
+		lbl.SetProperty("halign", gtk.ALIGN_START)
+		lbl.SetProperty("hexpand", true)
+		lbl.SetLineWrap(true)
+		lbl.SetMaxWidthChars(60)
+		lbl.SetXAlign(0)
+		if k.Description != "" {
+			lbl.SetTooltipText(k.Description)
+		}
+		g.Attach(lbl, 0, row, 1, 1)
+
+		widget, setValue := schemaKeyWidget(schema.ID, k, current)
+		g.Attach(widget, 1, row, 1, 1)
+
+		btn, _ := gtk.ButtonNewFromIconName("edit-undo-symbolic", gtk.ICON_SIZE_BUTTON)
+		btn.SetProperty("valign", gtk.ALIGN_CENTER)
+		btn.SetTooltipText(fmt.Sprintf("%s: %s", voc["reset-to-default"], k.Default))
+		btn.Connect("clicked", func() {
+			setValue(k.Default)
+			setAdvancedGsetting(schema.ID, k.Name, k.Default)
+		})
+		g.Attach(btn, 2, row, 1, 1)
+	}
+
+	return g
+}
+
+// schemaKeyWidget returns a widget to edit the key of the given type, and a function to set its value from GVariant text
+func schemaKeyWidget(schemaID string, k gschemaKey, current string) (gtk.IWidget, func(string)) {
+	if k.Type == "b" {
+		sw, _ := gtk.SwitchNew()
+		sw.SetProperty("halign", gtk.ALIGN_START)
+		sw.SetProperty("valign", gtk.ALIGN_CENTER)
+		sw.SetActive(current == "true")
+		sw.Connect("notify::active", func() {
+			setAdvancedGsetting(schemaID, k.Name, strconv.FormatBool(sw.GetActive()))
+		})
+		return sw, func(text string) {
+			sw.SetActive(text == "true")
+		}
+	}
+
+	if len(k.nicks) > 0 && k.Flags == "" {
+		combo, _ := gtk.ComboBoxTextNew()
+		combo.SetProperty("valign", gtk.ALIGN_CENTER)
+		for _, nick := range k.nicks {
+			combo.Append(nick, nick)
+		}
+		combo.SetActiveID(gvariantUnquote(current))
+		combo.Connect("changed", func() {
+			setAdvancedGsetting(schemaID, k.Name, gvariantQuote(combo.GetActiveID()))
+		})
+		return combo, func(text string) {
+			combo.SetActiveID(gvariantUnquote(text))
+		}
+	}
+
+	if min, max, ok := gschemaKeyRange(k); ok {
+		step := 1.0
+		if k.Type == "d" {
+			step = 0.01
+		}
+		sb, _ := gtk.SpinButtonNewWithRange(min, max, step)
+		sb.SetProperty("valign", gtk.ALIGN_CENTER)
+		if v, err := strconv.ParseFloat(gvariantUnquote(current), 64); err == nil {
+			sb.SetValue(v)
+		}
+		sb.Connect("value-changed", func() {
+			if k.Type == "d" {
+				setAdvancedGsetting(schemaID, k.Name, strconv.FormatFloat(sb.GetValue(), 'f', -1, 64))
+			} else {
+				setAdvancedGsetting(schemaID, k.Name, strconv.FormatInt(int64(sb.GetValue()), 10))
+			}
+		})
+		return sb, func(text string) {
+			if v, err := strconv.ParseFloat(gvariantUnquote(text), 64); err == nil {
+				sb.SetValue(v)
+			}
+		}
+	}
+
+	entry, _ := gtk.EntryNew()
+	entry.SetProperty("valign", gtk.ALIGN_CENTER)
+	if k.Type == "s" {
+		entry.SetText(gvariantUnquote(current))
+	} else {
+		// arrays, flags and other types are edited as GVariant text
+		entry.SetText(current)
+		entry.SetTooltipText(fmt.Sprintf("GVariant type: %s", k.Type))
+	}
+	entry.Connect("changed", func() {
+		text, _ := entry.GetText()
+		if k.Type == "s" {
+			text = gvariantQuote(text)
+		}
+		setAdvancedGsetting(schemaID, k.Name, text)
+	})
+	return entry, func(text string) {
+		if k.Type == "s" {
+			entry.SetText(gvariantUnquote(text))
+		} else {
+			entry.SetText(text)
+		}
+	}
+}
+
 func setUpProgramSettingsForm() *gtk.Frame {
 	frame, _ := gtk.FrameNew(fmt.Sprintf("  %s  ", voc["program-settings"]))
 	frame.SetLabelAlign(0.5, 0.5)
@@ -693,6 +1317,46 @@ func setUpProgramSettingsForm() *gtk.Frame {
 	g.Attach(btn, 1, row, 1, 1)
 	row++
 
+	cbFontconfig, _ := gtk.CheckButtonNewWithLabel("~/.config/fontconfig/conf.d/50-nwg-look.conf")
+	cbFontconfig.SetActive(preferences.ExportFontconfig)
+	cbFontconfig.SetTooltipText(voc["export-fontconfig-tooltip"])
+	g.Attach(cbFontconfig, 0, row, 1, 1)
+
+	cbFamilies, _ := gtk.CheckButtonNewWithLabel(voc["fontconfig-families"])
+	cbFamilies.SetActive(preferences.FontconfigFamilies)
+	cbFamilies.SetSensitive(preferences.ExportFontconfig)
+	cbFamilies.SetTooltipText(voc["fontconfig-families-tooltip"])
+	cbFamilies.Connect("toggled", func() {
+		preferences.FontconfigFamilies = cbFamilies.GetActive()
+	})
+	g.Attach(cbFamilies, 1, row, 1, 1)
+
+	cbFontconfig.Connect("toggled", func() {
+		preferences.ExportFontconfig = cbFontconfig.GetActive()
+		cbFamilies.SetSensitive(preferences.ExportFontconfig)
+	})
+	row++
+
+	cbXresources, _ := gtk.CheckButtonNewWithLabel("~/.Xresources")
+	cbXresources.SetActive(preferences.ExportXresources)
+	cbXresources.SetTooltipText(voc["export-xresources-tooltip"])
+	g.Attach(cbXresources, 0, row, 1, 1)
+
+	cbXrdb, _ := gtk.CheckButtonNewWithLabel(voc["merge-with-xrdb"])
+	cbXrdb.SetActive(preferences.MergeXresources)
+	cbXrdb.SetSensitive(preferences.ExportXresources)
+	cbXrdb.SetTooltipText(voc["merge-with-xrdb-tooltip"])
+	cbXrdb.Connect("toggled", func() {
+		preferences.MergeXresources = cbXrdb.GetActive()
+	})
+	g.Attach(cbXrdb, 1, row, 1, 1)
+
+	cbXresources.Connect("toggled", func() {
+		preferences.ExportXresources = cbXresources.GetActive()
+		cbXrdb.SetSensitive(preferences.ExportXresources)
+	})
+	row++
+
 	if flatpakAvailable() {
 		lbl2, _ := gtk.LabelNew("")
 		lbl2.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["flatpak-settings"]))
@@ -718,10 +1382,60 @@ func setUpProgramSettingsForm() *gtk.Frame {
 
 		cb8, _ := gtk.CheckButtonNewWithLabel(voc["flatpak-install-current-gtk-theme"])
 		cb8.SetActive(preferences.FlatpakInstallCurrentGTKTheme)
+		g.Attach(cb8, 0, row, 1, 1)
+
+		cb9, _ := gtk.CheckButtonNewWithLabel(voc["flatpak-theme-extension"])
+		cb9.SetActive(preferences.FlatpakThemeExtension)
+		cb9.SetSensitive(preferences.FlatpakInstallCurrentGTKTheme)
+		cb9.SetTooltipText(voc["flatpak-theme-extension-tooltip"])
+		cb9.Connect("toggled", func() {
+			preferences.FlatpakThemeExtension = cb9.GetActive()
+		})
+		g.Attach(cb9, 1, row, 1, 1)
+
 		cb8.Connect("toggled", func() {
 			preferences.FlatpakInstallCurrentGTKTheme = cb8.GetActive()
+			cb9.SetSensitive(preferences.FlatpakInstallCurrentGTKTheme)
 		})
-		g.Attach(cb8, 0, row, 1, 1)
+		row++
+
+		cb10, _ := gtk.CheckButtonNewWithLabel(voc["flatpak-install-icon-themes"])
+		cb10.SetActive(preferences.FlatpakInstallIconThemes)
+		cb10.SetTooltipText(voc["flatpak-install-icon-themes-tooltip"])
+		cb10.Connect("toggled", func() {
+			preferences.FlatpakInstallIconThemes = cb10.GetActive()
+		})
+		g.Attach(cb10, 0, row, 1, 1)
+		row++
+
+		cb11, _ := gtk.CheckButtonNewWithLabel(voc["flatpak-gtk4-theme"])
+		cb11.SetActive(preferences.FlatpakGtk4Theme)
+		cb11.SetTooltipText(voc["flatpak-gtk4-theme-tooltip"])
+		cb11.Connect("toggled", func() {
+			preferences.FlatpakGtk4Theme = cb11.GetActive()
+		})
+		g.Attach(cb11, 0, row, 1, 1)
+		row++
+	}
+
+	if snapAvailable() {
+		lbl3, _ := gtk.LabelNew("")
+		lbl3.SetMarkup(fmt.Sprintf("<b>%s</b>", voc["snap-settings"]))
+		lbl3.SetProperty("halign", gtk.ALIGN_START)
+		g.Attach(lbl3, 0, row, 1, 1)
+		row++
+
+		cb12, _ := gtk.CheckButtonNewWithLabel(voc["snap-themes"])
+		cb12.SetActive(preferences.SnapThemes)
+		cb12.SetTooltipText(voc["snap-themes-tooltip"])
+		cb12.Connect("toggled", func() {
+			preferences.SnapThemes = cb12.GetActive()
+		})
+		g.Attach(cb12, 0, row, 1, 1)
+
+		btnReport, _ := gtk.ButtonNewWithLabel(voc["snap-report"])
+		btnReport.Connect("clicked", showSnapReport)
+		g.Attach(btnReport, 1, row, 1, 1)
 		row++
 	}
 
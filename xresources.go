@@ -0,0 +1,214 @@
+// Xresources exporter
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	xresourcesBlockBegin = "! BEGIN nwg-look: settings below are managed by nwg-look"
+	xresourcesBlockEnd   = "! END nwg-look"
+)
+
+func xresourcesFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".Xresources")
+}
+
+// xresourcesLines returns Xft and Xcursor resources matching current settings
+func xresourcesLines() []string {
+	var lines []string
+
+	if gtkConfig.xftDpi > 0 {
+		lines = append(lines, fmt.Sprintf("Xft.dpi: %v", gtkConfig.xftDpi/1024))
+	}
+
+	v := 0
+	if gsettings.fontAntialiasing != "none" {
+		v = 1
+	}
+	lines = append(lines, fmt.Sprintf("Xft.antialias: %v", v))
+
+	if gsettings.fontHinting != "none" {
+		v = 1
+	} else {
+		v = 0
+	}
+	lines = append(lines, fmt.Sprintf("Xft.hinting: %v", v))
+
+	var fh string
+	switch gsettings.fontHinting {
+	case "slight":
+		fh = "hintslight"
+	case "medium":
+		fh = "hintmedium"
+	case "full":
+		fh = "hintfull"
+	default:
+		fh = "hintnone"
+	}
+	lines = append(lines, fmt.Sprintf("Xft.hintstyle: %s", fh))
+
+	rgba := "none"
+	if gsettings.fontAntialiasing == "rgba" {
+		rgba = gsettings.fontRgbaOrder
+	}
+	lines = append(lines, fmt.Sprintf("Xft.rgba: %s", rgba))
+
+	if gsettings.cursorTheme != "" {
+		lines = append(lines, fmt.Sprintf("Xcursor.theme: %s", gsettings.cursorTheme))
+	}
+	if gsettings.cursorSize > 0 {
+		lines = append(lines, fmt.Sprintf("Xcursor.size: %v", gsettings.cursorSize))
+	}
+
+	return lines
+}
+
+// xresourceName returns the resource name of a `name: value` line, or an empty string
+func xresourceName(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "!") || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	name, _, ok := strings.Cut(line, ":")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
+// mergeXresources replaces the nwg-look block in the existing Xresources lines, leaving user content untouched
+func mergeXresources(original, managed []string) []string {
+	block := []string{xresourcesBlockBegin}
+	block = append(block, managed...)
+	block = append(block, xresourcesBlockEnd)
+
+	managedNames := make(map[string]bool)
+	for _, l := range managed {
+		managedNames[xresourceName(l)] = true
+	}
+
+	var lines []string
+	inBlock, blockWritten := false, false
+	for _, l := range original {
+		trimmed := strings.TrimSpace(l)
+		switch {
+		case trimmed == xresourcesBlockBegin:
+			inBlock = true
+		case trimmed == xresourcesBlockEnd && inBlock:
+			inBlock = false
+			if !blockWritten {
+				lines = append(lines, block...)
+				blockWritten = true
+			}
+		case inBlock:
+			continue
+		default:
+			if managedNames[xresourceName(trimmed)] {
+				log.Warnf("Xresources: '%s' is set outside the nwg-look block", xresourceName(trimmed))
+			}
+			lines = append(lines, l)
+		}
+	}
+
+	if !blockWritten {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+	}
+
+	return lines
+}
+
+func saveXresources() {
+	configFile := xresourcesFile()
+	log.Infof(">>> Exporting %s", configFile)
+
+	var original []string
+	if bytes, err := os.ReadFile(configFile); err == nil {
+		if text := strings.TrimSuffix(string(bytes), "\n"); text != "" {
+			original = strings.Split(text, "\n")
+		}
+	} else if !os.IsNotExist(err) {
+		log.Warnf("Couldn't read %s: %s", configFile, err)
+		return
+	}
+
+	managed := xresourcesLines()
+	lines := mergeXresources(original, managed)
+
+	for _, l := range lines {
+		log.Debug(l)
+	}
+
+	saveTextFile(lines, configFile)
+
+	if preferences.MergeXresources {
+		mergeXrdb(managed)
+	}
+}
+
+// clearXresources removes the nwg-look block from Xresources, if any. Resources already loaded with xrdb
+// stay in place until the X session ends.
+func clearXresources() {
+	configFile := xresourcesFile()
+	bytes, err := os.ReadFile(configFile)
+	if err != nil {
+		return
+	}
+	original := strings.Split(strings.TrimSuffix(string(bytes), "\n"), "\n")
+	if !isIn(original, xresourcesBlockBegin) {
+		return
+	}
+	log.Infof("Removing nwg-look block from '%s'", configFile)
+	saveTextFile(removeXresourcesBlock(original), configFile)
+}
+
+// removeXresourcesBlock returns the lines without the nwg-look block, and the blank line mergeXresources
+// adds before it
+func removeXresourcesBlock(original []string) []string {
+	var lines []string
+	inBlock := false
+	for _, l := range original {
+		trimmed := strings.TrimSpace(l)
+		switch {
+		case trimmed == xresourcesBlockBegin:
+			inBlock = true
+			if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+		case inBlock:
+			inBlock = trimmed != xresourcesBlockEnd
+		default:
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// mergeXrdb loads the given resources into the X server resource database, if X or Xwayland is available
+func mergeXrdb(resources []string) {
+	if os.Getenv("DISPLAY") == "" {
+		log.Debug("DISPLAY not set, skipping xrdb merge")
+		return
+	}
+	if _, err := exec.LookPath("xrdb"); err != nil {
+		log.Warn("xrdb not found, skipping merge")
+		return
+	}
+
+	cmd := exec.Command("xrdb", "-merge", "-nocpp")
+	cmd.Stdin = strings.NewReader(strings.Join(resources, "\n") + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Warnf("xrdb merge failed: %s %s", err, strings.TrimSpace(string(out)))
+	} else {
+		log.Info("Resources merged with xrdb")
+	}
+}